@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAuditLimit is the number of entries returned when the client does
+// not ask for a specific number.
+const defaultAuditLimit = 100
+
+// AuditQuery holds the filters of GET /admin/audit-log.
+type AuditQuery struct {
+	EntityType string    `form:"entityType" json:"entityType" binding:"omitempty,oneof=event attendee user"`
+	EntityId   string    `form:"entityId" json:"entityId"`
+	ActorId    string    `form:"actorId" json:"actorId"`
+	From       time.Time `form:"from" json:"from"`
+	To         time.Time `form:"to" json:"to"`
+	Limit      int       `form:"limit" json:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// requireAdmin lets only administrators through. It must run after
+// authMiddleware.
+func (app *application) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.getUserFromContext(c).Role != database.RoleAdmin {
+			app.errorResponse(c, errs.Forbidden("This resource is restricted to administrators"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// getAuditLog lists recorded changes
+//
+// @Summary Query the audit log
+// @Description List changes to users, events and attendees, newest first. Only administrators can read the audit log.
+// @Tags admin
+// @Produce json
+// @Param entityType query string false "Entity type" Enums(event, attendee, user)
+// @Param entityId query string false "Entity ID"
+// @Param actorId query string false "ID of the user who made the change"
+// @Param from query string false "Earliest time, inclusive (RFC 3339)"
+// @Param to query string false "Latest time, exclusive (RFC 3339)"
+// @Param limit query int false "Maximum number of entries (default 100, at most 1000)"
+// @Success 200 {array} database.AuditEntry
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/admin/audit-log [get]
+// @Security BearerAuth
+func (app *application) getAuditLog(c *gin.Context) {
+	var query AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		app.errorResponse(c, app.validationError(c, err))
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		app.errorResponse(c, errs.Validation("from must be before to"))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultAuditLimit
+	}
+
+	entries, err := app.models.Audit.Query(c.Request.Context(), database.AuditFilter{
+		EntityType: query.EntityType,
+		EntityId:   query.EntityId,
+		ActorId:    query.ActorId,
+		From:       query.From,
+		To:         query.To,
+		Limit:      query.Limit,
+	})
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
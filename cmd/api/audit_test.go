@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestGetAuditLog(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	admin, adminToken := ta.createUser(t, "Admin", "admin@example.com", "password123")
+	ta.store.users[admin.Id].Role = database.RoleAdmin
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(`{"name":"Go meetup","description":"Monthly gathering","date":"`+futureDate()+`","location":"Hanoi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	req.Header.Set("X-Request-ID", "create-request")
+	rec := httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	assertStatus(t, rec, http.StatusCreated)
+	event := decode[database.Event](t, rec)
+
+	body := validEventBody()
+	body["name"] = "Renamed meetup"
+	rec = ta.do(t, http.MethodPut, "/api/v1/events/"+event.Id, body, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		wantStatus int
+	}{
+		{"unauthenticated", "", "", http.StatusUnauthorized},
+		{"not an admin", "", ownerToken, http.StatusForbidden},
+		{"unknown entity type", "?entityType=venue", adminToken, http.StatusBadRequest},
+		{"limit too large", "?limit=5000", adminToken, http.StatusBadRequest},
+		{"malformed time", "?from=yesterday", adminToken, http.StatusBadRequest},
+		{"empty range", "?from=2030-01-02T00:00:00Z&to=2030-01-01T00:00:00Z", adminToken, http.StatusBadRequest},
+		{"admin", "", adminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodGet, "/api/v1/admin/audit-log"+tt.query, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/admin/audit-log?entityType=event&entityId="+event.Id+"&actorId="+owner.Id, nil, adminToken)
+	assertStatus(t, rec, http.StatusOK)
+	entries := decode[[]database.AuditEntry](t, rec)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+
+	update, create := entries[0], entries[1]
+	if update.Action != database.ActionUpdate || create.Action != database.ActionCreate {
+		t.Fatalf("actions = %q, %q, want update then create", update.Action, create.Action)
+	}
+	if create.RequestId != "create-request" || create.IP == "" {
+		t.Fatalf("create entry = %+v, want request ID and IP", create)
+	}
+	if change := update.Changes["name"]; change.Before != "Go meetup" || change.After != "Renamed meetup" {
+		t.Fatalf("name change = %+v, want Go meetup -> Renamed meetup", change)
+	}
+	if _, ok := update.Changes["location"]; ok {
+		t.Fatalf("unchanged location recorded: %+v", update.Changes)
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/admin/audit-log?limit=1", nil, adminToken)
+	assertStatus(t, rec, http.StatusOK)
+	if entries := decode[[]database.AuditEntry](t, rec); len(entries) != 1 || entries[0].Id != update.Id {
+		t.Fatalf("limited entries = %+v, want the latest update only", entries)
+	}
+}
@@ -1,20 +1,21 @@
 package main
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type RegisterUserRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
-	Name     string `json:"name" binding:"required,min=2"`
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+	Name     string `json:"name" binding:"required,notblank,min=2,max=100"`
 }
 
 type LoginUserRequest struct {
@@ -34,20 +35,20 @@ type LoginUserResponse struct {
 // @Produce  json
 // @Param   register body RegisterUserRequest true "User registration payload"
 // @Success 201 {object} map[string]interface{} "Successfully registered"
-// @Failure 400 {object} map[string]interface{} "Bad Request"
-// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Failure 400 {object} Problem "Bad Request"
+// @Failure 409 {object} Problem "Email already registered"
+// @Failure 500 {object} Problem "Internal Server Error"
 // @Router /register [post]
 func (app *application) registerUser(c *gin.Context) {
 	var register RegisterUserRequest
 
-	if err := c.ShouldBindJSON(&register); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !app.bindJSON(c, &register) {
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(register.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		app.errorResponse(c, err)
 		return
 	}
 
@@ -58,9 +59,13 @@ func (app *application) registerUser(c *gin.Context) {
 		Password: register.Password,
 	}
 
-	err = app.models.Users.Insert(user)
+	err = app.models.Users.Insert(c.Request.Context(), user)
+	if errors.Is(err, database.ErrDuplicate) {
+		app.errorResponse(c, errs.Conflict("A user with this email already exists"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		app.errorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user": user})
@@ -74,41 +79,45 @@ func (app *application) registerUser(c *gin.Context) {
 // @Produce  json
 // @Param   login body LoginUserRequest true "User login payload"
 // @Success 200 {object} LoginUserResponse "Successfully authenticated"
-// @Failure 400 {object} map[string]interface{} "Bad Request"
-// @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Failure 400 {object} Problem "Bad Request"
+// @Failure 401 {object} Problem "Unauthorized"
+// @Failure 500 {object} Problem "Internal Server Error"
 // @Router /login [post]
 func (app *application) loginUser(c *gin.Context) {
 	var auth LoginUserRequest
-	if err := c.ShouldBindJSON(&auth); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !app.bindJSON(c, &auth) {
 		return
 	}
-	existingUser, err := app.models.Users.GetByEmail(auth.Email)
-	fmt.Println("User:", err)
-	if existingUser == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	existingUser, err := app.models.Users.GetByEmail(c.Request.Context(), auth.Email)
+	if errs.Is(err, errs.KindNotFound) {
+		app.metrics.LoginFailed("password")
+		app.errorResponse(c, errs.Unauthorized("Invalid email or password"))
 		return
 	}
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		app.errorResponse(c, err)
 		return
 	}
 	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(auth.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		app.metrics.LoginFailed("password")
+		app.errorResponse(c, errs.Unauthorized("Invalid email or password"))
 		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": existingUser.Id,
-		"expire": time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	tokenString, err := token.SignedString([]byte(app.jwtSecret))
+	tokenString, err := app.issueToken(existingUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		app.errorResponse(c, err)
 		return
 	}
+	app.metrics.LoginSucceeded("password")
 	c.JSON(http.StatusOK, LoginUserResponse{Token: tokenString})
 }
+
+// issueToken signs the JWT handed out to authenticated users.
+func (app *application) issueToken(user *database.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": user.Id,
+		"expire": time.Now().Add(time.Hour * 24).Unix(),
+	})
+	return token.SignedString([]byte(app.config.JWTSecret))
+}
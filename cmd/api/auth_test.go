@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestRegisterUser(t *testing.T) {
+	ta := newTestApp(t)
+
+	valid := map[string]any{"email": "jane@example.com", "password": "password123", "name": "Jane"}
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+	}{
+		{"registered", valid, http.StatusCreated},
+		{"duplicate email", valid, http.StatusConflict},
+		{"malformed json", `{"email":`, http.StatusBadRequest},
+		{"invalid email", map[string]any{"email": "jane", "password": "password123", "name": "Jane"}, http.StatusBadRequest},
+		{"short password", map[string]any{"email": "joe@example.com", "password": "short", "name": "Joe"}, http.StatusBadRequest},
+		{"wrong type", map[string]any{"email": "joe@example.com", "password": 12345678, "name": "Joe"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, "/api/v1/auth/register", tt.body, "")
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusCreated {
+				decodeProblem(t, rec)
+				return
+			}
+			if strings.Contains(rec.Body.String(), "password") {
+				t.Fatalf("response leaks the password hash: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	ta := newTestApp(t)
+	ta.createUser(t, "Jane", "jane@example.com", "password123")
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+	}{
+		{"logged in", map[string]any{"email": "jane@example.com", "password": "password123"}, http.StatusOK},
+		{"wrong password", map[string]any{"email": "jane@example.com", "password": "password456"}, http.StatusUnauthorized},
+		{"unknown email", map[string]any{"email": "nobody@example.com", "password": "password123"}, http.StatusUnauthorized},
+		{"malformed json", `not json`, http.StatusBadRequest},
+		{"missing fields", map[string]any{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, "/api/v1/auth/login", tt.body, "")
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+				return
+			}
+
+			token := decode[LoginUserResponse](t, rec).Token
+			rec = ta.do(t, http.MethodGet, "/api/v1/me", nil, token)
+			assertStatus(t, rec, http.StatusOK)
+		})
+	}
+}
+
+func TestRequestMagicLink(t *testing.T) {
+	ta := newTestApp(t)
+	ta.createUser(t, "Jane", "jane@example.com", "password123")
+
+	for _, email := range []string{"jane@example.com", "nobody@example.com"} {
+		rec := ta.do(t, http.MethodPost, "/api/v1/auth/magic-link", map[string]any{"email": email}, "")
+		assertStatus(t, rec, http.StatusAccepted)
+		if !strings.Contains(rec.Body.String(), magicLinkSentMessage) {
+			t.Fatalf("unexpected body for %s: %s", email, rec.Body.String())
+		}
+	}
+
+	sent := ta.mail.all()
+	if len(sent) != 1 || sent[0].Recipient != "jane@example.com" {
+		t.Fatalf("sent %+v, want a single email to jane@example.com", sent)
+	}
+	if !strings.Contains(sent[0].Body, ta.config.MagicLink.URL+"?token=") {
+		t.Fatalf("email does not contain the login link: %s", sent[0].Body)
+	}
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/auth/magic-link", map[string]any{"email": "not-an-email"}, "")
+	assertStatus(t, rec, http.StatusBadRequest)
+	decodeProblem(t, rec)
+}
+
+func TestMagicLinkRateLimit(t *testing.T) {
+	ta := newTestApp(t)
+	ta.magicLinkIPLimiter = newRateLimiter(1, time.Hour)
+	ta.handler = ta.routes()
+
+	body := map[string]any{"email": "jane@example.com"}
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/auth/magic-link", body, ""), http.StatusAccepted)
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/auth/magic-link", body, "")
+	assertStatus(t, rec, http.StatusTooManyRequests)
+	decodeProblem(t, rec)
+
+	// Consuming a link has a quota of its own.
+	consume := map[string]any{"token": strings.Repeat("A", 26)}
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/auth/magic-link/consume", consume, ""), http.StatusUnauthorized)
+}
+
+func TestConsumeMagicLink(t *testing.T) {
+	ta := newTestApp(t)
+	user, _ := ta.createUser(t, "Jane", "jane@example.com", "password123")
+	token, err := ta.models.Tokens.New(context.Background(), user.Id, time.Minute, database.ScopeMagicLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+	}{
+		{"malformed token", map[string]any{"token": "short"}, http.StatusBadRequest},
+		{"unknown token", map[string]any{"token": strings.Repeat("A", 26)}, http.StatusUnauthorized},
+		{"logged in", map[string]any{"token": token.Plaintext}, http.StatusOK},
+		{"token already used", map[string]any{"token": token.Plaintext}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, "/api/v1/auth/magic-link/consume", tt.body, "")
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+				return
+			}
+			if decode[LoginUserResponse](t, rec).Token == "" {
+				t.Fatal("no JWT returned")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/tracing"
+	"github.com/davidcm146/event-rest-api/internal/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type      string `json:"type" example:"https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.5"`
+	Title     string `json:"title" example:"Not Found"`
+	Status    int    `json:"status" example:"404"`
+	Detail    string `json:"detail,omitempty" example:"Event not found"`
+	Instance  string `json:"instance" example:"/api/v1/events/0b6f7c1e-5d0a-4a43-9b44-5c8e2f1f8f6d"`
+	RequestId string `json:"requestId,omitempty"`
+	TraceId   string `json:"traceId,omitempty"`
+	// Errors lists the rejected fields of a validation problem.
+	Errors []errs.FieldError `json:"errors,omitempty"`
+}
+
+// problemTypes points each status at the section of the HTTP specification
+// that defines it.
+var problemTypes = map[int]string{
+	http.StatusBadRequest:           "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.1",
+	http.StatusUnauthorized:         "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.2",
+	http.StatusForbidden:            "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.4",
+	http.StatusNotFound:             "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.5",
+	http.StatusMethodNotAllowed:     "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.6",
+	http.StatusConflict:             "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.10",
+	http.StatusPreconditionFailed:   "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.13",
+	http.StatusUnsupportedMediaType: "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.16",
+	http.StatusUnprocessableEntity:  "https://datatracker.ietf.org/doc/html/rfc9110#section-15.5.21",
+	http.StatusTooManyRequests:      "https://datatracker.ietf.org/doc/html/rfc6585#section-4",
+	http.StatusInternalServerError:  "https://datatracker.ietf.org/doc/html/rfc9110#section-15.6.1",
+}
+
+var kindStatus = map[errs.Kind]int{
+	errs.KindNotFound:     http.StatusNotFound,
+	errs.KindConflict:     http.StatusConflict,
+	errs.KindForbidden:    http.StatusForbidden,
+	errs.KindValidation:   http.StatusBadRequest,
+	errs.KindUnauthorized: http.StatusUnauthorized,
+	errs.KindPrecondition: http.StatusPreconditionFailed,
+}
+
+const internalErrorDetail = "The server encountered a problem and could not process your request"
+
+// problem writes an application/problem+json response and aborts the chain.
+func (app *application) problem(c *gin.Context, status int, detail string) {
+	app.writeProblem(c, status, detail, nil)
+}
+
+func (app *application) writeProblem(c *gin.Context, status int, detail string, fields []errs.FieldError) {
+	problemType, ok := problemTypes[status]
+	if !ok {
+		problemType = "about:blank"
+	}
+
+	p := Problem{
+		Type:      problemType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		RequestId: c.GetString("requestId"),
+		TraceId:   tracing.TraceID(c.Request.Context()),
+		Errors:    fields,
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, p)
+}
+
+// errorResponse renders err as a problem. Typed errors use their kind and
+// client message; anything else is logged and answered with a generic 500 so
+// internal details never reach the client. The trace ID in the body lets
+// operators find the matching logs and spans.
+func (app *application) errorResponse(c *gin.Context, err error) {
+	var e *errs.Error
+	if errors.As(err, &e) {
+		if status, ok := kindStatus[e.Kind]; ok {
+			app.writeProblem(c, status, e.Message, e.Fields)
+			return
+		}
+	}
+
+	app.logger.ErrorContext(c.Request.Context(), "request failed", slog.Any("error", err))
+	app.problem(c, http.StatusInternalServerError, internalErrorDetail)
+}
+
+// bindJSON decodes the request body into dst and renders a 400 problem when
+// it is malformed or fails validation, listing the rejected fields in the
+// client's language. It reports whether decoding succeeded.
+func (app *application) bindJSON(c *gin.Context, dst any) bool {
+	if err := c.ShouldBindJSON(dst); err != nil {
+		app.errorResponse(c, app.validationError(c, err))
+		return false
+	}
+	return true
+}
+
+// validationError converts a decoding or validation failure into a
+// validation error with messages in the client's language.
+func (app *application) validationError(c *gin.Context, err error) error {
+	return validation.Error(err, app.translators.For(c.GetHeader("Accept-Language")))
+}
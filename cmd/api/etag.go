@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// eventETag is the strong entity tag of an event. The version changes on
+// every write, so it identifies the representation exactly.
+func eventETag(event *database.Event) string {
+	return `"` + strconv.Itoa(event.Version) + `"`
+}
+
+// contentETag is a strong entity tag derived from a response body. It is used
+// for listings, which have no version of their own.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header lists etag.
+// "*" matches any current representation. Weak tags only match when weak is
+// set, as If-Match requires the strong comparison (RFC 9110 section 8.8.3.2).
+func matchesETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces an If-Match precondition against the current event.
+// Requests without the header are unconditional.
+func checkIfMatch(c *gin.Context, event *database.Event) error {
+	header := c.GetHeader("If-Match")
+	if header == "" || matchesETag(header, eventETag(event), false) {
+		return nil
+	}
+	return errs.PreconditionFailed("The event has been modified since it was fetched")
+}
+
+// checkEditable rejects changes to the content of an event that has been
+// cancelled or completed.
+func checkEditable(event *database.Event) error {
+	if database.IsFinal(event.Status) {
+		return errs.Conflict("A " + event.Status + " event can no longer be edited")
+	}
+	return nil
+}
+
+// writeError reports a failed conditional write. When the client sent
+// If-Match, losing the race to another writer is a failed precondition
+// rather than a plain conflict.
+func (app *application) writeError(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrEditConflict) && c.GetHeader("If-Match") != "" {
+		err = errs.PreconditionFailed("The event has been modified since it was fetched")
+	}
+	app.errorResponse(c, err)
+}
+
+// notModified tags the response with etag and, when the client's
+// If-None-Match already names it, answers 304 without a body.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if !matchesETag(c.GetHeader("If-None-Match"), etag, true) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// cacheableJSON writes data as a conditional 200 for responses without a
+// version: the tag is computed from the encoded body.
+func (app *application) cacheableJSON(c *gin.Context, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if notModified(c, contentETag(body)) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
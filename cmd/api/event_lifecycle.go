@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// CancelEventRequest is the body of POST /events/:id/cancel.
+type CancelEventRequest struct {
+	Reason string `json:"reason" binding:"required,notblank,max=500"`
+}
+
+// publishEvent makes a draft visible to everyone
+//
+// @Summary Publish an event
+// @Description Move a draft event to published, making it visible to everyone
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Success 200 {object} database.Event
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/publish [post]
+// @Security BearerAuth
+func (app *application) publishEvent(c *gin.Context) {
+	event, ok := app.eventForTransition(c, database.EventPublished)
+	if !ok {
+		return
+	}
+	app.transitionEvent(c, event, database.EventPublished, "")
+}
+
+// cancelEvent cancels an event and notifies its attendees
+//
+// @Summary Cancel an event
+// @Description Cancel a draft or published event. Attendees are emailed the reason, and no new attendees can be added.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param request body CancelEventRequest true "Why the event is cancelled"
+// @Param If-Match header string false "ETag the change is based on"
+// @Success 200 {object} database.Event
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/cancel [post]
+// @Security BearerAuth
+func (app *application) cancelEvent(c *gin.Context) {
+	event, ok := app.eventForTransition(c, database.EventCancelled)
+	if !ok {
+		return
+	}
+
+	var request CancelEventRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	app.transitionEvent(c, event, database.EventCancelled, request.Reason)
+}
+
+// completeEvent marks an event that has taken place as completed
+//
+// @Summary Complete an event
+// @Description Mark a published event as completed once its date has been reached
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Success 200 {object} database.Event
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/complete [post]
+// @Security BearerAuth
+func (app *application) completeEvent(c *gin.Context) {
+	event, ok := app.eventForTransition(c, database.EventCompleted)
+	if !ok {
+		return
+	}
+
+	date, err := utils.ParseDate(event.Date)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if date.After(time.Now()) {
+		app.errorResponse(c, errs.Conflict("An event cannot be completed before its date"))
+		return
+	}
+
+	app.transitionEvent(c, event, database.EventCompleted, "")
+}
+
+// getEventTransitions returns the status history of an event
+//
+// @Summary Get event status history
+// @Description List the status changes of an event, oldest first. Only the owner can see them.
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {array} database.EventTransition
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/transitions [get]
+// @Security BearerAuth
+func (app *application) getEventTransitions(c *gin.Context) {
+	id := c.Param("id")
+	user := app.getUserFromContext(c)
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, errs.Forbidden("You are not authorized to view the history of this event"))
+		return
+	}
+
+	transitions, err := app.models.Events.GetTransitions(c.Request.Context(), id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, transitions)
+}
+
+// eventForTransition loads the event named in the path and checks that the
+// user owns it, that the If-Match precondition holds and that it may move to
+// status to. It writes the error response and reports false otherwise.
+func (app *application) eventForTransition(c *gin.Context, to string) (*database.Event, bool) {
+	id := c.Param("id")
+	user := app.getUserFromContext(c)
+
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return nil, false
+	}
+
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, errs.Forbidden("You are not authorized to change the status of this event"))
+		return nil, false
+	}
+
+	if err := checkIfMatch(c, event); err != nil {
+		app.errorResponse(c, err)
+		return nil, false
+	}
+
+	if !database.CanTransition(event.Status, to) {
+		app.errorResponse(c, errs.Conflict("The event cannot move from "+event.Status+" to "+to))
+		return nil, false
+	}
+	return event, true
+}
+
+// transitionEvent applies and records the status change and writes the
+// updated event.
+func (app *application) transitionEvent(c *gin.Context, event *database.Event, to, reason string) {
+	user := app.getUserFromContext(c)
+
+	if _, err := app.models.Events.Transition(c.Request.Context(), event, to, reason, user.Id); err != nil {
+		app.writeError(c, err)
+		return
+	}
+	c.Header("ETag", eventETag(event))
+	c.JSON(http.StatusOK, event)
+}
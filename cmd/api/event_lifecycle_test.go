@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestDraftVisibility(t *testing.T) {
+	ta := newTestApp(t)
+	_, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+	draft := decode[database.Event](t, rec)
+	if draft.Status != database.EventDraft {
+		t.Fatalf("new event status = %q, want %q", draft.Status, database.EventDraft)
+	}
+
+	path := "/api/v1/events/" + draft.Id
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"anonymous", path, "", http.StatusNotFound},
+		{"another user", path, otherToken, http.StatusNotFound},
+		{"invalid token", path, "not-a-jwt", http.StatusUnauthorized},
+		{"owner", path, ownerToken, http.StatusOK},
+		{"anonymous listing", "/api/v1/events", "", http.StatusNotFound},
+		{"owner listing", "/api/v1/events", ownerToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodGet, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	rec = ta.do(t, http.MethodPost, path+"/publish", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	rec = ta.do(t, http.MethodGet, path, nil, "")
+	assertStatus(t, rec, http.StatusOK)
+}
+
+func TestEventLifecycle(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	latecomer, _ := ta.createUser(t, "Latecomer", "late@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+	event := decode[database.Event](t, rec)
+	path := "/api/v1/events/" + event.Id
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	steps := []struct {
+		name       string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+		wantState  string
+	}{
+		{"publish by another user", path + "/publish", guestToken, nil, http.StatusForbidden, ""},
+		{"publish unknown event", "/api/v1/events/missing/publish", ownerToken, nil, http.StatusNotFound, ""},
+		{"complete a draft", path + "/complete", ownerToken, nil, http.StatusConflict, ""},
+		{"publish", path + "/publish", ownerToken, nil, http.StatusOK, database.EventPublished},
+		{"publish twice", path + "/publish", ownerToken, nil, http.StatusConflict, ""},
+		{"complete before the date", path + "/complete", ownerToken, nil, http.StatusConflict, ""},
+		{"cancel without a reason", path + "/cancel", ownerToken, map[string]any{}, http.StatusBadRequest, ""},
+		{"cancel", path + "/cancel", ownerToken, map[string]any{"reason": "The venue is closed"}, http.StatusOK, database.EventCancelled},
+		{"cancel twice", path + "/cancel", ownerToken, map[string]any{"reason": "Again"}, http.StatusConflict, ""},
+		{"publish a cancelled event", path + "/publish", ownerToken, nil, http.StatusConflict, ""},
+		{"rsvp to a cancelled event", path + "/attendees/" + latecomer.Id, ownerToken, nil, http.StatusConflict, ""},
+	}
+	for _, step := range steps {
+		rec := ta.do(t, http.MethodPost, step.path, step.body, step.token)
+		assertStatus(t, rec, step.wantStatus)
+		if step.wantStatus != http.StatusOK {
+			decodeProblem(t, rec)
+			continue
+		}
+		if got := decode[database.Event](t, rec); got.Status != step.wantState {
+			t.Fatalf("%s: status = %q, want %q", step.name, got.Status, step.wantState)
+		}
+	}
+
+	// A cancelled event is final, so its content cannot change either.
+	for _, edit := range []struct{ method, path string }{
+		{http.MethodPut, path},
+		{http.MethodPatch, path},
+		{http.MethodPost, path + "/revisions/1/revert"},
+	} {
+		rec := ta.do(t, edit.method, edit.path, validEventBody(), ownerToken)
+		assertStatus(t, rec, http.StatusConflict)
+		decodeProblem(t, rec)
+	}
+
+	ta.flushOutbox()
+	sent := ta.mail.all()
+	if len(sent) != 1 || sent[0].Recipient != guest.Email || !strings.Contains(sent[0].Body, "The venue is closed") {
+		t.Fatalf("sent %+v, want one cancellation email with the reason to %s", sent, guest.Email)
+	}
+
+	rec = ta.do(t, http.MethodGet, path+"/transitions", nil, guestToken)
+	assertStatus(t, rec, http.StatusForbidden)
+	rec = ta.do(t, http.MethodGet, path+"/transitions", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	history := decode[[]database.EventTransition](t, rec)
+	if len(history) != 2 {
+		t.Fatalf("got %d transitions, want 2", len(history))
+	}
+	if h := history[0]; h.From != database.EventDraft || h.To != database.EventPublished || h.ActorId != owner.Id {
+		t.Fatalf("first transition = %+v", h)
+	}
+	if h := history[1]; h.To != database.EventCancelled || h.Reason != "The venue is closed" {
+		t.Fatalf("second transition = %+v", h)
+	}
+}
+
+func TestCompleteEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Yesterday's meetup")
+	ta.store.mu.Lock()
+	ta.store.events[event.Id].Date = time.Now().AddDate(0, 0, -1).Format("02/01/2006")
+	ta.store.mu.Unlock()
+
+	path := "/api/v1/events/" + event.Id + "/complete"
+	rec := ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id, nil, "")
+	etag := rec.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	req.Header.Set("If-Match", `"0"`)
+	rec = httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	assertStatus(t, rec, http.StatusPreconditionFailed)
+
+	req = httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	req.Header.Set("If-Match", etag)
+	rec = httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	assertStatus(t, rec, http.StatusOK)
+	if got := decode[database.Event](t, rec); got.Status != database.EventCompleted {
+		t.Fatalf("status = %q, want %q", got.Status, database.EventCompleted)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// RevisionDiffQuery selects the two revisions compared by
+// GET /events/:id/revisions/diff.
+type RevisionDiffQuery struct {
+	From int `form:"from" json:"from" binding:"required,min=1"`
+	To   int `form:"to" json:"to" binding:"required,min=1"`
+}
+
+// RevisionDiff lists the fields that changed between two revisions.
+type RevisionDiff struct {
+	From    int                             `json:"from"`
+	To      int                             `json:"to"`
+	Changes map[string]database.AuditChange `json:"changes"`
+}
+
+// getEventRevisions lists the revisions of an event
+//
+// @Summary Get event revisions
+// @Description List the saved revisions of an event, newest first. A revision is kept for every version of the event, including status changes and moves to and from the trash. Only the owner can see them.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {array} database.EventRevision
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/revisions [get]
+// @Security BearerAuth
+func (app *application) getEventRevisions(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to view the history of this event")
+	if !ok {
+		return
+	}
+
+	revisions, err := app.models.Events.GetRevisions(c.Request.Context(), event.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// getEventRevision returns a single revision of an event
+//
+// @Summary Get an event revision
+// @Description Get the content of an event as of a revision. Only the owner can see it.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param version path int true "Revision number"
+// @Success 200 {object} database.EventRevision
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/revisions/{version} [get]
+// @Security BearerAuth
+func (app *application) getEventRevision(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to view the history of this event")
+	if !ok {
+		return
+	}
+
+	revision, err := app.revision(c, event.Id, c.Param("version"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// diffEventRevisions compares two revisions of an event
+//
+// @Summary Compare event revisions
+// @Description List the fields that differ between two revisions of an event, with their values in each. Only the owner can compare revisions.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param from query int true "Earlier revision number"
+// @Param to query int true "Later revision number"
+// @Success 200 {object} RevisionDiff
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/revisions/diff [get]
+// @Security BearerAuth
+func (app *application) diffEventRevisions(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to view the history of this event")
+	if !ok {
+		return
+	}
+
+	var query RevisionDiffQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		app.errorResponse(c, app.validationError(c, err))
+		return
+	}
+
+	from, err := app.models.Events.GetRevision(c.Request.Context(), event.Id, query.From)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	to, err := app.models.Events.GetRevision(c.Request.Context(), event.Id, query.To)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	changes, err := database.DiffRevisions(from, to)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RevisionDiff{From: from.Version, To: to.Version, Changes: changes})
+}
+
+// revertEvent restores the content of an earlier revision
+//
+// @Summary Revert an event to a revision
+// @Description Restore the name, description, date, location and visibility settings of an earlier revision. The revert is saved as a new revision; the status is left alone. Cancelled and completed events cannot be reverted.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param version path int true "Revision number to restore"
+// @Param If-Match header string false "ETag the change is based on"
+// @Success 200 {object} database.Event
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/revisions/{version}/revert [post]
+// @Security BearerAuth
+func (app *application) revertEvent(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to update this event")
+	if !ok {
+		return
+	}
+
+	if err := checkIfMatch(c, event); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := checkEditable(event); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	revision, err := app.revision(c, event.Id, c.Param("version"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	revision.Apply(event)
+
+	if err := app.models.Events.Update(c.Request.Context(), event); err != nil {
+		app.writeError(c, err)
+		return
+	}
+	c.Header("ETag", eventETag(event))
+	c.JSON(http.StatusOK, event)
+}
+
+// revision loads the revision named by a path parameter. Malformed numbers
+// name no revision.
+func (app *application) revision(c *gin.Context, eventId, param string) (*database.EventRevision, error) {
+	version, err := strconv.Atoi(param)
+	if err != nil {
+		return nil, errs.NotFound("Revision not found")
+	}
+	return app.models.Events.GetRevision(c.Request.Context(), eventId, version)
+}
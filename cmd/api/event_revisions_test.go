@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestEventRevisions(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+	event := decode[database.Event](t, rec)
+	path := "/api/v1/events/" + event.Id
+
+	body := validEventBody()
+	body["description"] = "Accidentally overwritten"
+	body["location"] = "Saigon"
+	rec = ta.do(t, http.MethodPut, path, body, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+
+	rec = ta.do(t, http.MethodGet, path+"/revisions", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	revisions := decode[[]database.EventRevision](t, rec)
+	if len(revisions) != 2 || revisions[0].Version != 2 || revisions[1].Version != 1 {
+		t.Fatalf("revisions = %+v, want versions 2 and 1", revisions)
+	}
+	if revisions[0].EditorId != owner.Id || revisions[0].Description != "Accidentally overwritten" {
+		t.Fatalf("latest revision = %+v, want the owner's edit", revisions[0])
+	}
+
+	rec = ta.do(t, http.MethodGet, path+"/revisions/diff?from=1&to=2", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	diff := decode[RevisionDiff](t, rec)
+	if len(diff.Changes) != 2 || diff.Changes["location"].Before != "Hanoi" || diff.Changes["location"].After != "Saigon" {
+		t.Fatalf("diff = %+v, want description and location changes", diff)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"list not the owner", http.MethodGet, path + "/revisions", otherToken, http.StatusForbidden},
+		{"get", http.MethodGet, path + "/revisions/1", ownerToken, http.StatusOK},
+		{"get missing", http.MethodGet, path + "/revisions/9", ownerToken, http.StatusNotFound},
+		{"get malformed", http.MethodGet, path + "/revisions/first", ownerToken, http.StatusNotFound},
+		{"diff missing bound", http.MethodGet, path + "/revisions/diff?from=1", ownerToken, http.StatusBadRequest},
+		{"diff missing revision", http.MethodGet, path + "/revisions/diff?from=1&to=9", ownerToken, http.StatusNotFound},
+		{"revert not the owner", http.MethodPost, path + "/revisions/1/revert", otherToken, http.StatusForbidden},
+		{"revert missing revision", http.MethodPost, path + "/revisions/9/revert", ownerToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, tt.method, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path+"/revisions/1/revert", nil)
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	req.Header.Set("If-Match", `"1"`)
+	rec = httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	assertStatus(t, rec, http.StatusPreconditionFailed)
+
+	rec = ta.do(t, http.MethodPost, path+"/revisions/1/revert", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	reverted := decode[database.Event](t, rec)
+	if reverted.Version != 3 || reverted.Location != "Hanoi" || strings.Contains(reverted.Description, "overwritten") {
+		t.Fatalf("reverted event = %+v, want version 3 with the original content", reverted)
+	}
+	if got := rec.Header().Get("ETag"); got != `"3"` {
+		t.Fatalf("ETag = %s, want \"3\"", got)
+	}
+
+	rec = ta.do(t, http.MethodGet, path+"/revisions/diff?from=1&to=3", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	if diff := decode[RevisionDiff](t, rec); len(diff.Changes) != 0 {
+		t.Fatalf("revert left differences: %+v", diff.Changes)
+	}
+}
+
+func TestEventRevisionsCoverEveryVersion(t *testing.T) {
+	ta := newTestApp(t)
+	_, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+	path := "/api/v1/events/" + decode[database.Event](t, rec).Id
+
+	assertStatus(t, ta.do(t, http.MethodPost, path+"/publish", nil, ownerToken), http.StatusOK)
+	assertStatus(t, ta.do(t, http.MethodDelete, path, nil, ownerToken), http.StatusNoContent)
+	rec = ta.do(t, http.MethodPost, path+"/restore", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	current := decode[database.Event](t, rec)
+
+	// Every version the event went through, and so every ETag it served,
+	// has a revision.
+	rec = ta.do(t, http.MethodGet, path+"/revisions", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	revisions := decode[[]database.EventRevision](t, rec)
+	if len(revisions) != current.Version {
+		t.Fatalf("got %d revisions, want one for each of the %d versions", len(revisions), current.Version)
+	}
+	for i, revision := range revisions {
+		if revision.Version != current.Version-i {
+			t.Fatalf("revisions = %+v, want versions %d down to 1", revisions, current.Version)
+		}
+	}
+	rec = ta.do(t, http.MethodGet, path+"/revisions/diff?from=1&to="+strconv.Itoa(current.Version), nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+}
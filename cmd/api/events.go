@@ -1,174 +1,485 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/mergepatch"
+	"github.com/davidcm146/event-rest-api/internal/validation"
 	"github.com/gin-gonic/gin"
 )
 
+// CreateEventRequest is the body of POST /events. The owner is always the
+// authenticated user. Events are public unless a visibility is given.
+type CreateEventRequest struct {
+	Name            string `json:"name" binding:"required,notblank,min=3,max=200"`
+	Description     string `json:"description" binding:"required,notblank,min=10,max=5000"`
+	Date            string `json:"date" binding:"required,datetime=02/01/2006,future"`
+	Location        string `json:"location" binding:"max=200"`
+	Visibility      string `json:"visibility" binding:"omitempty,oneof=public unlisted private" enums:"public,unlisted,private"`
+	AttendeesPublic bool   `json:"attendeesPublic"`
+}
+
+// UpdateEventRequest holds the editable fields of an event. PUT requires all
+// of them except the visibility settings, which keep their current value
+// when omitted; PATCH merges the supplied ones into the stored event.
+type UpdateEventRequest struct {
+	Name            string `json:"name" binding:"required,notblank,min=3,max=200"`
+	Description     string `json:"description" binding:"required,notblank,min=10,max=5000"`
+	Date            string `json:"date" binding:"required,datetime=02/01/2006,future"`
+	Location        string `json:"location" binding:"max=200"`
+	Visibility      string `json:"visibility" binding:"omitempty,oneof=public unlisted private" enums:"public,unlisted,private"`
+	AttendeesPublic *bool  `json:"attendeesPublic"`
+}
+
+func (r UpdateEventRequest) apply(event *database.Event) {
+	event.Name = r.Name
+	event.Description = r.Description
+	event.Date = r.Date
+	event.Location = r.Location
+	if r.Visibility != "" {
+		event.Visibility = r.Visibility
+	}
+	if r.AttendeesPublic != nil {
+		event.AttendeesPublic = *r.AttendeesPublic
+	}
+}
+
 // createEvent creates a new event
 //
 // @Summary Create a new event
-// @Description Create a new event
+// @Description Create a new draft event owned by the authenticated user. Drafts are only visible to their owner until published.
 // @Tags events
 // @Accept json
 // @Produce json
-// @Param event body database.Event true "Event to create"
+// @Param event body CreateEventRequest true "Event to create"
+// @Param Idempotency-Key header string false "Key making retries of this request safe"
 // @Success 201 {object} database.Event
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 201 {string} ETag "Entity tag of the event"
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 422 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events [post]
 // @Security BearerAuth
 func (app *application) createEvent(c *gin.Context) {
-	var event database.Event
+	user := app.getUserFromContext(c)
 
-	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var request CreateEventRequest
+	if !app.bindJSON(c, &request) {
 		return
 	}
 
-	err := app.models.Events.Insert(&event)
+	event := database.Event{
+		Name:        request.Name,
+		OwnerId:     user.Id,
+		Description: request.Description,
+		Date:        request.Date,
+		Location:    request.Location,
+
+		Visibility:      request.Visibility,
+		AttendeesPublic: request.AttendeesPublic,
+	}
+	err := app.models.Events.Insert(c.Request.Context(), &event)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		app.errorResponse(c, err)
 		return
 	}
+	app.metrics.EventsCreated.Inc()
+	c.Header("ETag", eventETag(&event))
 	c.JSON(http.StatusCreated, event)
 }
 
 // getAllEvents return all events
 //
 // @Summary Returns all events
-// @Description Returns all events
+// @Description Returns the public events, plus the private events the caller is invited to or attending and the caller's own events when a token is sent. Drafts and unlisted events of others are left out.
 // @Tags events
 // @Accept json
 // @Produce json
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} []database.Event
+// @Header 200 {string} ETag "Entity tag of the response"
+// @Success 304 "Not modified"
 // @Router /api/v1/events [get]
+// @Security BearerAuth
 func (app *application) getAllEvents(c *gin.Context) {
-	events, err := app.models.Events.GetAll()
+	user := app.getUserFromContext(c)
+	events, err := app.models.Events.GetAll(c.Request.Context(), user.Id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving events"})
+		app.errorResponse(c, err)
 		return
 	}
 
 	if len(events) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No events found"})
+		app.errorResponse(c, errs.NotFound("No events found"))
 		return
 	}
-	c.JSON(http.StatusOK, &events)
+	app.cacheableJSON(c, events)
 }
 
 // getEvent returns an event by ID
 //
 // @Summary Get event by ID
-// @Description Get a single event by its ID
+// @Description Get a single event by its ID. Drafts are only returned to their owner, and private events to their owner, invitees and attendees.
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param id path string true "Event ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} database.Event
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Entity tag of the response"
+// @Success 304 "Not modified"
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events/{id} [get]
+// @Security BearerAuth
 func (app *application) getEvent(c *gin.Context) {
 	id := c.Param("id")
+	user := app.getUserFromContext(c)
 
-	event, err := app.models.Events.Get(id)
-
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	event, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
 	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving event"})
+	if !app.checkCanView(c, event, user) {
+		return
+	}
+	if notModified(c, eventETag(event)) {
+		return
 	}
 	c.JSON(http.StatusOK, event)
 }
 
+// updateEvent replaces the editable fields of an event
+//
 // @Summary Update an event
-// @Description Update an event by its ID
+// @Description Replace all editable fields of an event. Use PATCH to change only some of them. Cancelled and completed events can no longer be edited.
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param id path string true "Event ID"
-// @Param event body database.Event true "Updated event data"
+// @Param event body UpdateEventRequest true "Updated event data"
+// @Param If-Match header string false "ETag the change is based on"
 // @Success 200 {object} database.Event
-// @Failure 400 {object} map[string]string
-// @Failure 403 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events/{id} [put]
 // @Security BearerAuth
 func (app *application) updateEvent(c *gin.Context) {
 	id := c.Param("id")
 
 	user := app.getUserFromContext(c)
-	existingEvent, err := app.models.Events.Get(id)
-
+	existingEvent, err := app.models.Events.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving event"})
+		app.errorResponse(c, err)
+		return
+	}
+
+	if existingEvent.OwnerId != user.Id {
+		app.errorResponse(c, errs.Forbidden("You are not authorized to update this event"))
+		return
+	}
+
+	if err := checkIfMatch(c, existingEvent); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := checkEditable(existingEvent); err != nil {
+		app.errorResponse(c, err)
+		return
 	}
 
-	if existingEvent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	var request UpdateEventRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+	request.apply(existingEvent)
+
+	if err := app.models.Events.Update(c.Request.Context(), existingEvent); err != nil {
+		app.writeError(c, err)
+		return
+	}
+	c.Header("ETag", eventETag(existingEvent))
+	c.JSON(http.StatusOK, existingEvent)
+}
+
+// patchEvent applies a JSON Merge Patch to an event
+//
+// @Summary Partially update an event
+// @Description Apply a JSON Merge Patch (RFC 7396) to an event. Only the supplied fields are changed and validated; null clears the location. Cancelled and completed events can no longer be edited.
+// @Tags events
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param event body UpdateEventRequest true "Fields to change"
+// @Param If-Match header string false "ETag the change is based on"
+// @Success 200 {object} database.Event
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 415 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id} [patch]
+// @Security BearerAuth
+func (app *application) patchEvent(c *gin.Context) {
+	id := c.Param("id")
+
+	user := app.getUserFromContext(c)
+	existingEvent, err := app.models.Events.Get(c.Request.Context(), id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
 	}
 
 	if existingEvent.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to update this event"})
+		app.errorResponse(c, errs.Forbidden("You are not authorized to update this event"))
+		return
+	}
+
+	if err := checkIfMatch(c, existingEvent); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := checkEditable(existingEvent); err != nil {
+		app.errorResponse(c, err)
 		return
 	}
-	updatedEvent := &database.Event{}
 
-	if err := c.ShouldBindJSON(updatedEvent); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if c.ContentType() != mergepatch.ContentType {
+		app.problem(c, http.StatusUnsupportedMediaType, "The request body must be sent as "+mergepatch.ContentType)
+		return
 	}
-	updatedEvent.Id = id
 
-	if err := app.models.Events.Update(updatedEvent); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating event"})
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		app.errorResponse(c, errs.E(errs.KindValidation, "The request body could not be read", err))
 		return
 	}
-	c.JSON(http.StatusOK, updatedEvent)
+
+	fields, err := mergepatch.Keys(patch)
+	if err != nil {
+		app.errorResponse(c, app.patchError(c, err))
+		return
+	}
+
+	current := UpdateEventRequest{
+		Name:        existingEvent.Name,
+		Description: existingEvent.Description,
+		Date:        existingEvent.Date,
+		Location:    existingEvent.Location,
+
+		Visibility:      existingEvent.Visibility,
+		AttendeesPublic: &existingEvent.AttendeesPublic,
+	}
+	document, err := json.Marshal(current)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	patched, err := mergepatch.Apply(document, patch)
+	if err != nil {
+		app.errorResponse(c, app.patchError(c, err))
+		return
+	}
+
+	var request UpdateEventRequest
+	if err := json.Unmarshal(patched, &request); err != nil {
+		app.errorResponse(c, app.patchError(c, err))
+		return
+	}
+	if err := validation.Partial(&request, fields...); err != nil {
+		app.errorResponse(c, app.patchError(c, err))
+		return
+	}
+	request.apply(existingEvent)
+
+	if err := app.models.Events.Update(c.Request.Context(), existingEvent); err != nil {
+		app.writeError(c, err)
+		return
+	}
+	c.Header("ETag", eventETag(existingEvent))
+	c.JSON(http.StatusOK, existingEvent)
 }
 
-// deleteEvent deletes an event
+// patchError describes why a merge patch was rejected.
+func (app *application) patchError(c *gin.Context, err error) error {
+	if errors.Is(err, mergepatch.ErrNotObject) {
+		return errs.E(errs.KindValidation, "The merge patch must be a JSON object", err)
+	}
+	return app.validationError(c, err)
+}
+
+// deleteEvent moves an event to the trash
 //
 // @Summary Delete an event
-// @Description Delete an event by its ID
+// @Description Move an event to the trash and notify its attendees. The owner can restore it within the retention period, after which it is purged.
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param id path string true "Event ID"
+// @Param If-Match header string false "ETag the change is based on"
 // @Success 204 {object} nil
-// @Failure 403 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events/{id} [delete]
 // @Security BearerAuth
 func (app *application) deleteEvent(c *gin.Context) {
 	id := c.Param("id")
 	user := app.getUserFromContext(c)
-	existingEvent, err := app.models.Events.Get(id)
+	existingEvent, err := app.models.Events.Get(c.Request.Context(), id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving event"})
+		app.errorResponse(c, err)
 		return
 	}
 
 	if existingEvent.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to delete this event"})
+		app.errorResponse(c, errs.Forbidden("You are not authorized to delete this event"))
+		return
+	}
+
+	if err := checkIfMatch(c, existingEvent); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.models.Events.Delete(c.Request.Context(), id, existingEvent.Version); err != nil {
+		app.writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// mailCancellation is the outbox subscriber that emails every attendee of a
+// cancelled or deleted event, including the organizer's reason when one was
+// given. Failed emails are logged rather than retried, so that the other
+// attendees are not emailed twice.
+func (app *application) mailCancellation(ctx context.Context, msg *database.OutboxMessage) error {
+	var change database.EventChanged
+	if err := json.Unmarshal(msg.Payload, &change); err != nil {
+		return err
+	}
+	event := change.Event
+
+	note := "\nIf the organizer restores it, your attendance will be restored with it.\n"
+	if change.Reason != "" {
+		note = "\nReason given by the organizer: " + change.Reason + "\n"
+	}
+	for _, attendee := range change.Attendees {
+		body := fmt.Sprintf("Hi %s,\n\nThe event \"%s\" you were attending has been cancelled by its organizer.\n%s",
+			attendee.Name, event.Name, note)
+		if err := app.mailer.Send(attendee.Email, "Event cancelled: "+event.Name, body); err != nil {
+			app.logger.ErrorContext(ctx, "event cancelled: failed to send email", "event_id", event.Id, "error", err)
+		}
+	}
+	return nil
+}
+
+// scheduleReminder is the outbox subscriber that queues the reminder of an
+// event that was published, or saved while published.
+func (app *application) scheduleReminder(ctx context.Context, msg *database.OutboxMessage) error {
+	var change database.EventChanged
+	if err := json.Unmarshal(msg.Payload, &change); err != nil {
+		return err
+	}
+	return app.tasks.ScheduleReminder(ctx, change.Event)
+}
+
+// getDeletedEvents lists the events in the user's trash
+//
+// @Summary List deleted events
+// @Description List the authenticated user's deleted events that can still be restored, most recently deleted first
+// @Tags events
+// @Produce json
+// @Success 200 {array} database.Event
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me/deleted-events [get]
+// @Security BearerAuth
+func (app *application) getDeletedEvents(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	events, err := app.models.Events.GetDeletedByOwner(c.Request.Context(), user.Id, app.retentionStart())
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// restoreEvent takes an event out of the trash
+//
+// @Summary Restore a deleted event
+// @Description Restore an event deleted within the retention period, together with its attendees
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Success 200 {object} database.Event
+// @Header 200 {string} ETag "Entity tag of the event"
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/restore [post]
+// @Security BearerAuth
+func (app *application) restoreEvent(c *gin.Context) {
+	id := c.Param("id")
+	user := app.getUserFromContext(c)
+
+	event, err := app.models.Events.GetDeleted(c.Request.Context(), id, app.retentionStart())
+	if err != nil {
+		app.errorResponse(c, err)
 		return
 	}
 
-	if err := app.models.Events.Delete(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting event"})
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, errs.Forbidden("You are not authorized to restore this event"))
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	if err := checkIfMatch(c, event); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.models.Events.Restore(c.Request.Context(), event); err != nil {
+		app.writeError(c, err)
+		return
+	}
+	c.Header("ETag", eventETag(event))
+	c.JSON(http.StatusOK, event)
+}
+
+// retentionStart is the earliest deletion time of an event that can still
+// be restored.
+func (app *application) retentionStart() time.Time {
+	return time.Now().AddDate(0, 0, -app.config.Events.RetentionDays)
 }
 
 // addAttendeeToEvent adds a user as attendee to event
@@ -180,11 +491,13 @@ func (app *application) deleteEvent(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Event ID"
 // @Param userId path string true "User ID to add as attendee"
+// @Param Idempotency-Key header string false "Key making retries of this request safe"
 // @Success 201 {object} map[string]interface{}
-// @Failure 403 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 409 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 422 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events/{id}/attendees/{userId} [post]
 // @Security BearerAuth
 func (app *application) addAttendeeToEvent(c *gin.Context) {
@@ -192,41 +505,25 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 	userId := c.Param("userId")
 	user := app.getUserFromContext(c)
 
-	event, err := app.models.Events.Get(eventId)
+	event, err := app.models.Events.Get(c.Request.Context(), eventId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving event"})
-		return
-	}
-
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		app.errorResponse(c, err)
 		return
 	}
 
 	if event.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to add attendees to this event"})
+		app.errorResponse(c, errs.Forbidden("You are not authorized to add attendees to this event"))
 		return
 	}
 
-	userToAdd, err := app.models.Users.GetById(userId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user"})
+	if event.Status == database.EventCancelled || event.Status == database.EventCompleted {
+		app.errorResponse(c, errs.Conflict("Attendees cannot be added to an event that is "+event.Status))
 		return
 	}
 
-	if userToAdd == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	existingAttendee, err := app.models.Attendees.GetByEventAndAttendee(event.Id, userToAdd.Id)
+	userToAdd, err := app.models.Users.GetById(c.Request.Context(), userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking existing attendee"})
-		return
-	}
-
-	if existingAttendee != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User is already an attendee of this event"})
+		app.errorResponse(c, err)
 		return
 	}
 
@@ -235,34 +532,77 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 		UserId:  userToAdd.Id,
 	}
 
-	_, err = app.models.Attendees.Insert(attendee)
+	_, err = app.models.Attendees.Insert(c.Request.Context(), attendee)
+	if errors.Is(err, database.ErrDuplicate) {
+		app.errorResponse(c, errs.Conflict("User is already an attendee of this event"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding attendee to event"})
+		app.errorResponse(c, err)
 		return
 	}
+	app.metrics.RSVPs.Inc()
 	c.JSON(http.StatusCreated, gin.H{"message": "User added to event successfully", "attendee": attendee})
 }
 
+// PublicAttendee is what users other than the organizer see of an attendee.
+type PublicAttendee struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
 // getAttendeesByEvent returns list of attendees for event
 //
 // @Summary Get attendees for event
-// @Description Get list of users attending an event
+// @Description Get list of users attending an event. Only the owner can see it unless the event's attendee list is public. Everyone but the owner only gets the id and name of each attendee.
 // @Tags attendees
 // @Accept json
 // @Produce json
 // @Param id path string true "Event ID"
-// @Success 200 {array} database.User
-// @Failure 500 {object} map[string]string
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Success 200 {array} PublicAttendee
+// @Header 200 {string} ETag "Entity tag of the response"
+// @Success 304 "Not modified"
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events/{id}/attendees [get]
+// @Security BearerAuth
 func (app *application) getAttendeesByEvent(c *gin.Context) {
 	eventId := c.Param("id")
+	user := app.getUserFromContext(c)
 
-	users, err := app.models.Attendees.GetAttendeesByEventId(eventId)
+	event, err := app.models.Events.Get(c.Request.Context(), eventId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving attendees for event"})
+		app.errorResponse(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, users)
+
+	if !app.checkCanView(c, event, user) {
+		return
+	}
+
+	if !event.AttendeesPublic && event.OwnerId != user.Id {
+		app.errorResponse(c, errs.Forbidden("The attendee list of this event is not public"))
+		return
+	}
+
+	users, err := app.models.Attendees.GetAttendeesByEventId(c.Request.Context(), eventId)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if user.Id != "" && event.OwnerId == user.Id {
+		app.cacheableJSON(c, users)
+		return
+	}
+
+	// Email addresses are only for the organizer.
+	attendees := make([]PublicAttendee, len(users))
+	for i, u := range users {
+		attendees[i] = PublicAttendee{Id: u.Id, Name: u.Name}
+	}
+	app.cacheableJSON(c, attendees)
 }
 
 // removeAttendeeFromEvent removes an attendee from event
@@ -275,45 +615,34 @@ func (app *application) getAttendeesByEvent(c *gin.Context) {
 // @Param id path string true "Event ID"
 // @Param userId path string true "User ID to remove"
 // @Success 200 {object} map[string]string
-// @Failure 403 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/events/{id}/attendees/{userId} [delete]
 // @Security BearerAuth
 func (app *application) removeAttendeeFromEvent(c *gin.Context) {
 	eventId := c.Param("id")
 	userId := c.Param("userId")
 	user := app.getUserFromContext(c)
-	event, err := app.models.Events.Get(eventId)
+	event, err := app.models.Events.Get(c.Request.Context(), eventId)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving event"})
-		return
-	}
-
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-		return
-	}
-
-	attendee, err := app.models.Attendees.GetByEventAndAttendee(eventId, userId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving attendee"})
+		app.errorResponse(c, err)
 		return
 	}
 
 	if event.OwnerId != user.Id {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to add attendees to this event"})
+		app.errorResponse(c, errs.Forbidden("You are not authorized to add attendees to this event"))
 		return
 	}
 
-	if attendee == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Attendee not found"})
+	if _, err := app.models.Attendees.GetByEventAndAttendee(c.Request.Context(), eventId, userId); err != nil {
+		app.errorResponse(c, err)
 		return
 	}
 
-	if err := app.models.Attendees.Delete(userId, eventId); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing attendee from event"})
+	if err := app.models.Attendees.Delete(c.Request.Context(), userId, eventId); err != nil {
+		app.errorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Attendee removed from event successfully"})
@@ -322,26 +651,38 @@ func (app *application) removeAttendeeFromEvent(c *gin.Context) {
 // getEventsByAttendee returns events an attendee is participating
 //
 // @Summary Get events by attendee
-// @Description Get events that a user is attending
+// @Description Get events that a user is attending. Other callers only see public events with a public attendee list.
 // @Tags attendees
 // @Accept json
 // @Produce json
 // @Param id path string true "Attendee ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {array} database.Event
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Entity tag of the response"
+// @Success 304 "Not modified"
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /api/v1/attendees/{id}/events [get]
+// @Security BearerAuth
 func (app *application) getEventsByAttendee(c *gin.Context) {
 	id := c.Param("id")
+	user := app.getUserFromContext(c)
 
-	events, err := app.models.Attendees.GetEventsByAttendeeId(id)
+	events, err := app.models.Attendees.GetEventsByAttendeeId(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving events for attendee"})
+		app.errorResponse(c, err)
 		return
 	}
+
+	// Attendance is only disclosed where the attendee list itself is public.
+	if user.Id != id {
+		events = slices.DeleteFunc(events, func(e *database.Event) bool {
+			return e.Visibility != database.VisibilityPublic || !e.AttendeesPublic
+		})
+	}
 	if len(events) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No events found for this attendee"})
+		app.errorResponse(c, errs.NotFound("No events found for this attendee"))
 		return
 	}
-	c.JSON(http.StatusOK, events)
+	app.cacheableJSON(c, events)
 }
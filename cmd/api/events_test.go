@@ -0,0 +1,560 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/mergepatch"
+)
+
+func validEventBody() map[string]any {
+	return map[string]any{
+		"name":        "Go meetup",
+		"description": "Monthly gathering of Go developers",
+		"date":        futureDate(),
+		"location":    "Hanoi",
+	}
+}
+
+func TestGetAllEvents(t *testing.T) {
+	ta := newTestApp(t)
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/events", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	decodeProblem(t, rec)
+
+	owner, _ := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	ta.createEvent(t, owner.Id, "First")
+	ta.createEvent(t, owner.Id, "Second")
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/events", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	if events := decode[[]database.Event](t, rec); len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+}
+
+func TestGetEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, _ := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id, nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	if got := decode[database.Event](t, rec); got.Id != event.Id || got.Name != event.Name {
+		t.Fatalf("got event %+v, want %+v", got, event)
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/events/missing", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	if p := decodeProblem(t, rec); p.Detail != "Event not found" || p.Instance != "/api/v1/events/missing" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+}
+
+func TestCreateEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, token := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	other, _ := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	spoofedOwner := validEventBody()
+	spoofedOwner["ownerId"] = other.Id
+	pastDate := validEventBody()
+	pastDate["date"] = time.Now().AddDate(0, 0, -1).Format("02/01/2006")
+	blankName := validEventBody()
+	blankName["name"] = "    "
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+		wantField  string
+	}{
+		{"created", token, validEventBody(), http.StatusCreated, ""},
+		{"owner from body ignored", token, spoofedOwner, http.StatusCreated, ""},
+		{"missing token", "", validEventBody(), http.StatusUnauthorized, ""},
+		{"invalid token", "not-a-jwt", validEventBody(), http.StatusUnauthorized, ""},
+		{"malformed json", token, `{"name":`, http.StatusBadRequest, ""},
+		{"missing fields", token, map[string]any{}, http.StatusBadRequest, "name"},
+		{"date in the past", token, pastDate, http.StatusBadRequest, "date"},
+		{"blank name", token, blankName, http.StatusBadRequest, "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, "/api/v1/events", tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus == http.StatusCreated {
+				got := decode[database.Event](t, rec)
+				if got.Id == "" {
+					t.Fatal("created event has no id")
+				}
+				if got.OwnerId != owner.Id {
+					t.Fatalf("owner = %q, want the authenticated user %q", got.OwnerId, owner.Id)
+				}
+				return
+			}
+
+			p := decodeProblem(t, rec)
+			if tt.wantField == "" {
+				return
+			}
+			for _, fe := range p.Errors {
+				if fe.Field == tt.wantField {
+					return
+				}
+			}
+			t.Fatalf("no error for field %q in %+v", tt.wantField, p.Errors)
+		})
+	}
+}
+
+func TestUpdateEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+
+	updated := validEventBody()
+	updated["name"] = "Renamed meetup"
+
+	tests := []struct {
+		name       string
+		id         string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{"updated", event.Id, ownerToken, updated, http.StatusOK},
+		{"missing token", event.Id, "", updated, http.StatusUnauthorized},
+		{"not the owner", event.Id, otherToken, updated, http.StatusForbidden},
+		{"not found", "missing", ownerToken, updated, http.StatusNotFound},
+		{"malformed json", event.Id, ownerToken, `{`, http.StatusBadRequest},
+		{"invalid body", event.Id, ownerToken, map[string]any{"name": "x"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPut, "/api/v1/events/"+tt.id, tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	stored, _ := ta.models.Events.Get(t.Context(), event.Id)
+	if stored.Name != "Renamed meetup" || stored.OwnerId != owner.Id {
+		t.Fatalf("stored event = %+v, want renamed and still owned by %s", stored, owner.Id)
+	}
+}
+
+func TestPatchEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+
+	patch := func(id, token, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/events/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		ta.handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		id          string
+		token       string
+		contentType string
+		body        string
+		wantStatus  int
+		wantField   string
+	}{
+		{"missing token", event.Id, "", mergepatch.ContentType, `{"name":"Renamed"}`, http.StatusUnauthorized, ""},
+		{"not the owner", event.Id, otherToken, mergepatch.ContentType, `{"name":"Renamed"}`, http.StatusForbidden, ""},
+		{"not found", "missing", ownerToken, mergepatch.ContentType, `{"name":"Renamed"}`, http.StatusNotFound, ""},
+		{"wrong content type", event.Id, ownerToken, "application/json", `{"name":"Renamed"}`, http.StatusUnsupportedMediaType, ""},
+		{"malformed json", event.Id, ownerToken, mergepatch.ContentType, `{"name":`, http.StatusBadRequest, ""},
+		{"not an object", event.Id, ownerToken, mergepatch.ContentType, `["name"]`, http.StatusBadRequest, ""},
+		{"wrong type", event.Id, ownerToken, mergepatch.ContentType, `{"name":42}`, http.StatusBadRequest, "name"},
+		{"invalid value", event.Id, ownerToken, mergepatch.ContentType, `{"name":"x"}`, http.StatusBadRequest, "name"},
+		{"null required field", event.Id, ownerToken, mergepatch.ContentType, `{"description":null}`, http.StatusBadRequest, "description"},
+		{"patched", event.Id, ownerToken, mergepatch.ContentType, `{"name":"Renamed meetup","location":null,"ownerId":"someone-else"}`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := patch(tt.id, tt.token, tt.contentType, tt.body)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			p := decodeProblem(t, rec)
+			if tt.wantField == "" {
+				return
+			}
+			if len(p.Errors) != 1 || p.Errors[0].Field != tt.wantField {
+				t.Fatalf("got errors %+v, want one for %q", p.Errors, tt.wantField)
+			}
+		})
+	}
+
+	stored, _ := ta.models.Events.Get(t.Context(), event.Id)
+	want := *event
+	want.Name = "Renamed meetup"
+	want.Location = ""
+	want.Version = 2
+	if *stored != want {
+		t.Fatalf("stored event = %+v, want %+v", stored, want)
+	}
+}
+
+func TestDeleteEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+
+	rec := ta.do(t, http.MethodDelete, "/api/v1/events/"+event.Id, nil, "")
+	assertStatus(t, rec, http.StatusUnauthorized)
+
+	rec = ta.do(t, http.MethodDelete, "/api/v1/events/"+event.Id, nil, otherToken)
+	assertStatus(t, rec, http.StatusForbidden)
+
+	rec = ta.do(t, http.MethodDelete, "/api/v1/events/missing", nil, ownerToken)
+	assertStatus(t, rec, http.StatusNotFound)
+	decodeProblem(t, rec)
+
+	rec = ta.do(t, http.MethodDelete, "/api/v1/events/"+event.Id, nil, ownerToken)
+	assertStatus(t, rec, http.StatusNoContent)
+	if rec.Body.Len() != 0 {
+		t.Fatalf("204 response has a body: %q", rec.Body.String())
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id, nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+
+	rec = ta.do(t, http.MethodDelete, "/api/v1/events/"+event.Id, nil, ownerToken)
+	assertStatus(t, rec, http.StatusNotFound)
+}
+
+func TestDeleteEventNotifiesAttendees(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	rec := ta.do(t, http.MethodDelete, "/api/v1/events/"+event.Id, nil, ownerToken)
+	assertStatus(t, rec, http.StatusNoContent)
+
+	ta.flushOutbox()
+	sent := ta.mail.all()
+	if len(sent) != 1 || sent[0].Recipient != guest.Email || !strings.Contains(sent[0].Body, event.Name) {
+		t.Fatalf("sent %+v, want one cancellation email to %s", sent, guest.Email)
+	}
+
+	// Deleted events drop out of every listing.
+	rec = ta.do(t, http.MethodGet, "/api/v1/events", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	rec = ta.do(t, http.MethodGet, "/api/v1/attendees/"+guest.Id+"/events", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	rec = ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id+"/attendees", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+}
+
+func TestRestoreEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	expired := ta.createEvent(t, owner.Id, "Old meetup")
+	live := ta.createEvent(t, owner.Id, "Live meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	for _, id := range []string{event.Id, expired.Id} {
+		rec := ta.do(t, http.MethodDelete, "/api/v1/events/"+id, nil, ownerToken)
+		assertStatus(t, rec, http.StatusNoContent)
+	}
+	longAgo := time.Now().AddDate(0, 0, -ta.config.Events.RetentionDays-1)
+	ta.store.mu.Lock()
+	ta.store.events[expired.Id].DeletedAt = &longAgo
+	ta.store.mu.Unlock()
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/me/deleted-events", nil, "")
+	assertStatus(t, rec, http.StatusUnauthorized)
+	rec = ta.do(t, http.MethodGet, "/api/v1/me/deleted-events", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	trash := decode[[]database.Event](t, rec)
+	if len(trash) != 1 || trash[0].Id != event.Id || trash[0].DeletedAt == nil {
+		t.Fatalf("trash = %+v, want only %s", trash, event.Id)
+	}
+
+	restore := func(id string) string { return "/api/v1/events/" + id + "/restore" }
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"missing token", restore(event.Id), "", http.StatusUnauthorized},
+		{"not the owner", restore(event.Id), guestToken, http.StatusForbidden},
+		{"not deleted", restore(live.Id), ownerToken, http.StatusNotFound},
+		{"past retention", restore(expired.Id), ownerToken, http.StatusNotFound},
+		{"unknown event", restore("missing"), ownerToken, http.StatusNotFound},
+		{"restored", restore(event.Id), ownerToken, http.StatusOK},
+		{"already restored", restore(event.Id), ownerToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	// Attendance survives the round trip through the trash.
+	rec = ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id+"/attendees", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	if users := decode[[]database.User](t, rec); len(users) != 1 || users[0].Id != guest.Id {
+		t.Fatalf("attendees after restore = %+v, want %s", users, guest.Id)
+	}
+}
+
+func TestPurgeDeletedEvents(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	recent := ta.createEvent(t, owner.Id, "Recent meetup")
+	expired := ta.createEvent(t, owner.Id, "Old meetup")
+	live := ta.createEvent(t, owner.Id, "Live meetup")
+
+	for _, id := range []string{recent.Id, expired.Id} {
+		rec := ta.do(t, http.MethodDelete, "/api/v1/events/"+id, nil, ownerToken)
+		assertStatus(t, rec, http.StatusNoContent)
+	}
+	longAgo := time.Now().AddDate(0, 0, -ta.config.Events.RetentionDays-1)
+	ta.store.mu.Lock()
+	ta.store.events[expired.Id].DeletedAt = &longAgo
+	ta.store.mu.Unlock()
+
+	if err := ta.tasks.Start(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	ta.runJobs(time.Now())
+
+	ta.store.mu.Lock()
+	defer ta.store.mu.Unlock()
+	if _, ok := ta.store.events[expired.Id]; ok {
+		t.Fatal("event past retention was not purged")
+	}
+	for _, id := range []string{recent.Id, live.Id} {
+		if _, ok := ta.store.events[id]; !ok {
+			t.Fatalf("event %s was purged", id)
+		}
+	}
+}
+
+func TestAddAttendeeToEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+
+	path := "/api/v1/events/" + event.Id + "/attendees/" + guest.Id
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"missing token", path, "", http.StatusUnauthorized},
+		{"not the owner", path, guestToken, http.StatusForbidden},
+		{"event not found", "/api/v1/events/missing/attendees/" + guest.Id, ownerToken, http.StatusNotFound},
+		{"user not found", "/api/v1/events/" + event.Id + "/attendees/missing", ownerToken, http.StatusNotFound},
+		{"added", path, ownerToken, http.StatusCreated},
+		{"already attending", path, ownerToken, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusCreated {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+}
+
+func TestGetAttendeesByEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id+"/attendees", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	users := decode[[]database.User](t, rec)
+	if len(users) != 1 || users[0].Id != guest.Id || users[0].Name != guest.Name {
+		t.Fatalf("got attendees %+v, want only %s", users, guest.Id)
+	}
+
+	// Only the organizer sees email addresses.
+	for _, token := range []string{"", guestToken} {
+		rec = ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id+"/attendees", nil, token)
+		assertStatus(t, rec, http.StatusOK)
+		if strings.Contains(rec.Body.String(), guest.Email) {
+			t.Fatalf("attendee list exposes emails: %s", rec.Body.String())
+		}
+	}
+	rec = ta.do(t, http.MethodGet, "/api/v1/events/"+event.Id+"/attendees", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	if users := decode[[]database.User](t, rec); len(users) != 1 || users[0].Email != guest.Email {
+		t.Fatalf("owner got attendees %+v, want the guest's email", users)
+	}
+}
+
+func TestRemoveAttendeeFromEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	path := "/api/v1/events/" + event.Id + "/attendees/" + guest.Id
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"missing token", path, "", http.StatusUnauthorized},
+		{"not the owner", path, guestToken, http.StatusForbidden},
+		{"event not found", "/api/v1/events/missing/attendees/" + guest.Id, ownerToken, http.StatusNotFound},
+		{"removed", path, ownerToken, http.StatusOK},
+		{"no longer attending", path, ownerToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodDelete, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+}
+
+func TestGetEventsByAttendee(t *testing.T) {
+	ta := newTestApp(t)
+	owner, _ := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/attendees/"+guest.Id+"/events", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	decodeProblem(t, rec)
+
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/attendees/"+guest.Id+"/events", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	if events := decode[[]database.Event](t, rec); len(events) != 1 || events[0].Id != event.Id {
+		t.Fatalf("got events %+v, want only %s", events, event.Id)
+	}
+}
+
+func TestEventConditionalRequests(t *testing.T) {
+	ta := newTestApp(t)
+	owner, token := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, owner.Id)
+
+	conditional := func(method, path, header, etag string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", mergepatch.ContentType)
+		}
+		req.Header.Set(header, etag)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		ta.handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	path := "/api/v1/events/" + event.Id
+	rec := ta.do(t, http.MethodGet, path, nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %q, want %q", etag, `"1"`)
+	}
+
+	t.Run("get not modified", func(t *testing.T) {
+		rec := conditional(http.MethodGet, path, "If-None-Match", etag, "")
+		assertStatus(t, rec, http.StatusNotModified)
+		if rec.Body.Len() != 0 {
+			t.Fatalf("304 has a body: %s", rec.Body.String())
+		}
+		rec = conditional(http.MethodGet, path, "If-None-Match", "W/"+etag, "")
+		assertStatus(t, rec, http.StatusNotModified)
+		rec = conditional(http.MethodGet, path, "If-None-Match", `"0"`, "")
+		assertStatus(t, rec, http.StatusOK)
+	})
+
+	for _, listing := range []string{"/api/v1/events", "/api/v1/events/" + event.Id + "/attendees", "/api/v1/attendees/" + owner.Id + "/events"} {
+		t.Run("listing "+listing, func(t *testing.T) {
+			// The organizer gets a fuller attendee list, so fetch it as them.
+			rec := ta.do(t, http.MethodGet, listing, nil, token)
+			assertStatus(t, rec, http.StatusOK)
+			listingETag := rec.Header().Get("ETag")
+			if listingETag == "" {
+				t.Fatal("listing has no ETag")
+			}
+			rec = conditional(http.MethodGet, listing, "If-None-Match", listingETag, "")
+			assertStatus(t, rec, http.StatusNotModified)
+		})
+	}
+
+	t.Run("stale if-match", func(t *testing.T) {
+		rec := conditional(http.MethodPatch, path, "If-Match", `"0"`, `{"name":"Renamed"}`)
+		assertStatus(t, rec, http.StatusPreconditionFailed)
+		decodeProblem(t, rec)
+		rec = conditional(http.MethodDelete, path, "If-Match", `"0"`, "")
+		assertStatus(t, rec, http.StatusPreconditionFailed)
+		decodeProblem(t, rec)
+	})
+
+	t.Run("weak if-match", func(t *testing.T) {
+		rec := conditional(http.MethodPatch, path, "If-Match", "W/"+etag, `{"name":"Renamed"}`)
+		assertStatus(t, rec, http.StatusPreconditionFailed)
+	})
+
+	t.Run("matching if-match", func(t *testing.T) {
+		rec := conditional(http.MethodPatch, path, "If-Match", etag, `{"name":"Renamed"}`)
+		assertStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get("ETag"); got != `"2"` {
+			t.Fatalf("ETag after update = %q, want %q", got, `"2"`)
+		}
+		// The tag the client used is now stale.
+		rec = conditional(http.MethodPatch, path, "If-Match", etag, `{"name":"Renamed again"}`)
+		assertStatus(t, rec, http.StatusPreconditionFailed)
+		rec = conditional(http.MethodGet, path, "If-None-Match", etag, "")
+		assertStatus(t, rec, http.StatusOK)
+	})
+
+	t.Run("delete with current tag", func(t *testing.T) {
+		rec := conditional(http.MethodDelete, path, "If-Match", `"2"`, "")
+		assertStatus(t, rec, http.StatusNoContent)
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/export"
+	"github.com/gin-gonic/gin"
+)
+
+type ExportStatusResponse struct {
+	Export      *database.Export `json:"export"`
+	DownloadURL string           `json:"downloadUrl,omitempty"`
+}
+
+func (app *application) exportSignature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(app.config.JWTSecret))
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// exportDownloadURL returns a signed link that is valid until the export expires.
+func (app *application) exportDownloadURL(exp *database.Export) string {
+	expires := exp.ExpiresAt.Unix()
+	return fmt.Sprintf("%s/api/v1/exports/%s/download?expires=%d&signature=%s", app.config.BaseURL, exp.Id, expires, app.exportSignature(exp.Id, expires))
+}
+
+// exportPersonalData godoc
+// @Summary Export personal data
+// @Description Download a zip archive (JSON and CSV) of everything held about the authenticated user. Small exports are returned directly; large ones are generated in the background and a 202 with a status link is returned instead.
+// @Tags Me
+// @Produce application/zip
+// @Produce json
+// @Success 200 {file} file "Export archive"
+// @Success 202 {object} ExportStatusResponse
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me/export [get]
+// @Security BearerAuth
+func (app *application) exportPersonalData(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	// Counting is cheap; only small exports are collected while the client
+	// waits.
+	records, err := app.models.Exports.CountRecords(c.Request.Context(), user.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if records <= app.config.Export.SyncLimit {
+		data, err := export.Collect(c.Request.Context(), app.models, user)
+		if err != nil {
+			app.errorResponse(c, err)
+			return
+		}
+		var buf bytes.Buffer
+		if err := export.WriteZip(&buf, data); err != nil {
+			app.errorResponse(c, err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="export.zip"`)
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+		return
+	}
+
+	exp := &database.Export{
+		UserId:    user.Id,
+		Status:    database.ExportPending,
+		ExpiresAt: time.Now().Add(app.config.Export.TTL),
+	}
+	if err := app.models.Exports.Insert(c.Request.Context(), exp); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.tasks.QueueExport(c.Request.Context(), exp.Id); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/me/exports/"+exp.Id)
+	c.JSON(http.StatusAccepted, ExportStatusResponse{Export: exp})
+}
+
+// getExportStatus godoc
+// @Summary Get export status
+// @Description Returns the status of a background export, including a signed download link once it is completed
+// @Tags Me
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} ExportStatusResponse
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me/exports/{id} [get]
+// @Security BearerAuth
+func (app *application) getExportStatus(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	exp, err := app.models.Exports.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if exp.UserId != user.Id || time.Now().After(exp.ExpiresAt) {
+		app.errorResponse(c, errs.NotFound("Export not found"))
+		return
+	}
+
+	response := ExportStatusResponse{Export: exp}
+	if exp.Status == database.ExportCompleted {
+		response.DownloadURL = app.exportDownloadURL(exp)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// downloadExport godoc
+// @Summary Download an export
+// @Description Download a completed export using the signed link from the status endpoint. The link expires together with the export.
+// @Tags Me
+// @Produce application/zip
+// @Param id path string true "Export ID"
+// @Param expires query int true "Link expiry (unix seconds)"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} file "Export archive"
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/exports/{id}/download [get]
+func (app *application) downloadExport(c *gin.Context) {
+	id := c.Param("id")
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	signature := c.Query("signature")
+	if err != nil || !hmac.Equal([]byte(signature), []byte(app.exportSignature(id, expires))) {
+		app.errorResponse(c, errs.Forbidden("Invalid download link"))
+		return
+	}
+	if time.Now().Unix() > expires {
+		app.errorResponse(c, errs.Forbidden("Download link has expired"))
+		return
+	}
+
+	exp, err := app.models.Exports.Get(c.Request.Context(), id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if exp.Status != database.ExportCompleted {
+		app.errorResponse(c, errs.NotFound("Export not found"))
+		return
+	}
+
+	c.FileAttachment(exp.FilePath, "export.zip")
+}
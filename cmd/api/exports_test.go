@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestExportPersonalDataSync(t *testing.T) {
+	ta := newTestApp(t)
+	user, token := ta.createUser(t, "Jane", "jane@example.com", "password123")
+	organizer, _ := ta.createUser(t, "Organizer", "organizer@example.com", "password123")
+	ta.createEvent(t, user.Id, "Go meetup")
+	// Attendance of events in the trash is still held, so it is exported.
+	attended := ta.createEvent(t, organizer.Id, "Rust meetup")
+	ta.addAttendee(t, attended.Id, user.Id)
+	if err := ta.models.Events.Delete(t.Context(), attended.Id, attended.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/me/export", nil, "")
+	assertStatus(t, rec, http.StatusUnauthorized)
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/me/export", nil, token)
+	assertStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("Content-Type = %q, want application/zip", ct)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) == 0 || archive.File[0].Name != "export.json" {
+		t.Fatalf("unexpected archive contents: %v", archive.File)
+	}
+	f, err := archive.Open("attendance.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	attendance, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(attendance), attended.Id) {
+		t.Fatalf("attendance.csv = %q, want the trashed event", attendance)
+	}
+}
+
+func TestExportPersonalDataAsync(t *testing.T) {
+	ta := newTestApp(t)
+	ta.config.Export.SyncLimit = 0
+	user, token := ta.createUser(t, "Jane", "jane@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Joe", "joe@example.com", "password123")
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/me/export", nil, token)
+	assertStatus(t, rec, http.StatusAccepted)
+	location := rec.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/v1/me/exports/") {
+		t.Fatalf("Location = %q", location)
+	}
+	if status := decode[ExportStatusResponse](t, rec); status.Export.Status != database.ExportPending {
+		t.Fatalf("export = %+v, want it pending until the job runs", status.Export)
+	}
+	if n := ta.runJobs(time.Now()); n != 1 {
+		t.Fatalf("ran %d jobs, want the export to be generated", n)
+	}
+
+	rec = ta.do(t, http.MethodGet, location, nil, otherToken)
+	assertStatus(t, rec, http.StatusNotFound)
+	decodeProblem(t, rec)
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/me/exports/missing", nil, token)
+	assertStatus(t, rec, http.StatusNotFound)
+	decodeProblem(t, rec)
+
+	rec = ta.do(t, http.MethodGet, location, nil, token)
+	assertStatus(t, rec, http.StatusOK)
+	status := decode[ExportStatusResponse](t, rec)
+	if status.Export.Status != database.ExportCompleted || status.Export.UserId != user.Id {
+		t.Fatalf("unexpected export %+v", status.Export)
+	}
+	if !strings.HasPrefix(status.DownloadURL, ta.config.BaseURL) {
+		t.Fatalf("download URL %q is not absolute", status.DownloadURL)
+	}
+
+	download := strings.TrimPrefix(status.DownloadURL, ta.config.BaseURL)
+	rec = ta.do(t, http.MethodGet, download, nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	if _, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len())); err != nil {
+		t.Fatalf("download is not a zip archive: %v", err)
+	}
+}
+
+func TestDownloadExport(t *testing.T) {
+	ta := newTestApp(t)
+
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	signed := func(id string, expires int64) string {
+		return fmt.Sprintf("/api/v1/exports/%s/download?expires=%d&signature=%s", id, expires, ta.exportSignature(id, expires))
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"missing signature", "/api/v1/exports/export-1/download", http.StatusForbidden},
+		{"bad signature", fmt.Sprintf("/api/v1/exports/export-1/download?expires=%d&signature=deadbeef", future), http.StatusForbidden},
+		{"signature for another export", strings.Replace(signed("export-2", future), "export-2", "export-1", 1), http.StatusForbidden},
+		{"expired link", signed("export-1", past), http.StatusForbidden},
+		{"unknown export", signed("export-1", future), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodGet, tt.path, nil, "")
+			assertStatus(t, rec, tt.wantStatus)
+			decodeProblem(t, rec)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/database/migrations"
+	"github.com/gin-gonic/gin"
+)
+
+// healthz godoc
+// @Summary Liveness probe
+// @Description Reports that the process is up. It does not check dependencies.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (app *application) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz godoc
+// @Summary Readiness probe
+// @Description Reports whether the instance can serve traffic: the database is reachable, migrations are at the expected version and the server is not shutting down.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /readyz [get]
+func (app *application) readyz(c *gin.Context) {
+	if app.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := app.db.PingContext(ctx); err != nil {
+		app.logger.WarnContext(ctx, "readiness check failed: database unreachable", "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "database unreachable"})
+		return
+	}
+
+	expected, err := migrations.Latest()
+	if err != nil {
+		app.logger.ErrorContext(ctx, "readiness check failed: could not read embedded migrations", "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "schema version unknown"})
+		return
+	}
+
+	version, dirty, err := database.SchemaVersion(ctx, app.db)
+	if err != nil {
+		app.logger.WarnContext(ctx, "readiness check failed: could not read schema version", "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "schema version unknown"})
+		return
+	}
+	if dirty || version != expected {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unavailable",
+			"error":  fmt.Sprintf("schema at version %d (dirty: %t), expected %d", version, dirty, expected),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "schemaVersion": version})
+}
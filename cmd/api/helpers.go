@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+)
+
+// background runs fn in its own goroutine, recovering from any panic so a
+// failing side task (e.g. sending an email) cannot take the server down.
+// Shutdown waits for these tasks to finish.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error("background task panicked", "panic", fmt.Sprint(err))
+			}
+		}()
+		fn()
+	}()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// validIdempotencyKey accepts any key of visible ASCII characters, which
+// covers the UUIDs clients usually send.
+var validIdempotencyKey = regexp.MustCompile(`^[\x21-\x7E]{1,255}$`)
+
+// replayedHeaders are the response headers recorded with a response and sent
+// again when it is replayed.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// idempotencyPurgeInterval is how often expired keys are deleted.
+const idempotencyPurgeInterval = time.Hour
+
+// recordingWriter keeps a copy of the response body while writing it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent makes a POST safe to retry. The first request carrying a given
+// Idempotency-Key runs normally and its response is recorded for the
+// configured TTL; retries by the same user get the recorded response back
+// without running the handler again. While the first request is running,
+// retries get 409 until its lock times out and a retry can take the key
+// over. Reusing a key for a different request is rejected with 422.
+// Requests without the header are not affected. It must run after
+// authMiddleware, as keys are scoped to the user.
+func (app *application) idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey.MatchString(key) {
+			app.errorResponse(c, errs.Validation("The Idempotency-Key header must be 1 to 255 visible ASCII characters"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			app.errorResponse(c, errs.E(errs.KindValidation, "The request body could not be read", err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := app.getUserFromContext(c)
+		ctx := c.Request.Context()
+		record := &database.IdempotencyRecord{
+			UserId:      user.Id,
+			Key:         key,
+			Fingerprint: requestFingerprint(c.Request, body),
+			LockedUntil: time.Now().Add(app.config.Idempotency.LockTimeout),
+			ExpiresAt:   time.Now().Add(app.config.Idempotency.TTL),
+		}
+		existing, err := app.models.IdempotencyKeys.Reserve(ctx, record)
+		if err != nil {
+			app.errorResponse(c, err)
+			return
+		}
+		if existing != nil {
+			switch {
+			case !existing.Matches(record.Fingerprint):
+				app.problem(c, http.StatusUnprocessableEntity, "The idempotency key was already used for a different request")
+			case !existing.Completed():
+				app.errorResponse(c, errs.Conflict("A request with this idempotency key is already in progress"))
+			default:
+				replay(c, existing)
+			}
+			return
+		}
+
+		// Unless a response is recorded, give the key up again so a retry
+		// can run the request. This also covers panics in the handler.
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			err := app.models.IdempotencyKeys.Release(context.WithoutCancel(ctx), record)
+			if errors.Is(err, database.ErrLockLost) {
+				app.logger.WarnContext(ctx, "idempotency key was taken over before it was released", "key", key)
+			} else if err != nil {
+				app.logger.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+			}
+		}()
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// Server errors are not recorded; the request may succeed next time.
+		if w.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record.StatusCode = w.Status()
+		record.Header = http.Header{}
+		for _, name := range replayedHeaders {
+			if values := w.Header().Values(name); len(values) > 0 {
+				record.Header[name] = values
+			}
+		}
+		record.Body = w.body.Bytes()
+		err = app.models.IdempotencyKeys.Complete(context.WithoutCancel(ctx), record)
+		if errors.Is(err, database.ErrLockLost) {
+			// A retry holds the key now; its response is the one recorded.
+			app.logger.WarnContext(ctx, "idempotency key was taken over before the response was recorded", "key", key)
+			return
+		}
+		if err != nil {
+			app.logger.ErrorContext(ctx, "failed to record idempotent response", "error", err)
+			return
+		}
+		completed = true
+		app.purgeIdempotencyKeys()
+	}
+}
+
+// requestFingerprint identifies a request by its method, path and body.
+func requestFingerprint(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// replay sends a recorded response and stops the chain.
+func replay(c *gin.Context, record *database.IdempotencyRecord) {
+	for name, values := range record.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(record.StatusCode)
+	c.Writer.Write(record.Body)
+	c.Abort()
+}
+
+// purgeIdempotencyKeys deletes expired keys in the background, at most once
+// per idempotencyPurgeInterval. Expired keys are already ignored by Reserve;
+// this only keeps the table small.
+func (app *application) purgeIdempotencyKeys() {
+	now := time.Now().Unix()
+	last := app.idempotencyPurgedAt.Load()
+	if now-last < int64(idempotencyPurgeInterval/time.Second) || !app.idempotencyPurgedAt.CompareAndSwap(last, now) {
+		return
+	}
+	app.background(func() {
+		ctx := context.Background()
+		deleted, err := app.models.IdempotencyKeys.DeleteExpired(ctx)
+		if err != nil {
+			app.logger.ErrorContext(ctx, "failed to delete expired idempotency keys", "error", err)
+			return
+		}
+		app.logger.DebugContext(ctx, "deleted expired idempotency keys", "count", deleted)
+	})
+}
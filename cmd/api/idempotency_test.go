@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestIdempotentCreateEvent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, token := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	post := func(token, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		ta.handler.ServeHTTP(rec, req)
+		ta.wg.Wait()
+		return rec
+	}
+	body := `{"name":"Go meetup","description":"Monthly gathering of Go developers","date":"` + futureDate() + `","location":"Hanoi"}`
+	countEvents := func() int {
+		events, _ := ta.models.Events.GetByOwner(t.Context(), owner.Id)
+		return len(events)
+	}
+
+	first := post(token, "key-1", body)
+	assertStatus(t, first, http.StatusCreated)
+	created := decode[database.Event](t, first)
+
+	t.Run("retry replays the response", func(t *testing.T) {
+		rec := post(token, "key-1", body)
+		assertStatus(t, rec, http.StatusCreated)
+		if rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatal("replayed response is not marked")
+		}
+		if got := decode[database.Event](t, rec); got != created {
+			t.Fatalf("replayed %+v, want %+v", got, created)
+		}
+		if rec.Header().Get("ETag") != first.Header().Get("ETag") {
+			t.Fatalf("ETag = %q, want %q", rec.Header().Get("ETag"), first.Header().Get("ETag"))
+		}
+		if n := countEvents(); n != 1 {
+			t.Fatalf("owner has %d events, want 1", n)
+		}
+	})
+
+	t.Run("different payload", func(t *testing.T) {
+		rec := post(token, "key-1", strings.Replace(body, "Go meetup", "Rust meetup", 1))
+		assertStatus(t, rec, http.StatusUnprocessableEntity)
+		decodeProblem(t, rec)
+	})
+
+	t.Run("keys are per user", func(t *testing.T) {
+		rec := post(otherToken, "key-1", body)
+		assertStatus(t, rec, http.StatusCreated)
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatal("another user's response was replayed")
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		rec := post(token, strings.Repeat("k", 256), body)
+		assertStatus(t, rec, http.StatusBadRequest)
+		decodeProblem(t, rec)
+	})
+
+	t.Run("without a key", func(t *testing.T) {
+		before := countEvents()
+		assertStatus(t, post(token, "", body), http.StatusCreated)
+		assertStatus(t, post(token, "", body), http.StatusCreated)
+		if n := countEvents(); n != before+2 {
+			t.Fatalf("owner has %d events, want %d", n, before+2)
+		}
+	})
+
+	running := &database.IdempotencyRecord{
+		UserId:      owner.Id,
+		Key:         "key-running",
+		Fingerprint: requestFingerprint(httptest.NewRequest(http.MethodPost, "/api/v1/events", nil), []byte(body)),
+		LockedUntil: time.Now().Add(time.Minute),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	t.Run("request in progress", func(t *testing.T) {
+		if _, err := ta.models.IdempotencyKeys.Reserve(t.Context(), running); err != nil {
+			t.Fatal(err)
+		}
+		rec := post(token, "key-running", body)
+		assertStatus(t, rec, http.StatusConflict)
+		decodeProblem(t, rec)
+	})
+
+	t.Run("abandoned request", func(t *testing.T) {
+		before := countEvents()
+		// The lock of the request above runs out long before the key expires.
+		ta.store.mu.Lock()
+		ta.store.idempotency[owner.Id+"/key-running"].LockedUntil = time.Now().Add(-time.Second)
+		ta.store.mu.Unlock()
+
+		rec := post(token, "key-running", strings.Replace(body, "Go meetup", "Rust meetup", 1))
+		assertStatus(t, rec, http.StatusUnprocessableEntity)
+
+		rec = post(token, "key-running", body)
+		assertStatus(t, rec, http.StatusCreated)
+		if n := countEvents(); n != before+1 {
+			t.Fatalf("owner has %d events, want %d", n, before+1)
+		}
+		rec = post(token, "key-running", body)
+		if rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatal("response of the retry that took the key over was not recorded")
+		}
+
+		// The slow request finishing late can neither overwrite the recorded
+		// response nor give up the key.
+		running.StatusCode = http.StatusBadRequest
+		if err := ta.models.IdempotencyKeys.Complete(t.Context(), running); !errors.Is(err, database.ErrLockLost) {
+			t.Fatalf("Complete() = %v, want ErrLockLost", err)
+		}
+		if err := ta.models.IdempotencyKeys.Release(t.Context(), running); !errors.Is(err, database.ErrLockLost) {
+			t.Fatalf("Release() = %v, want ErrLockLost", err)
+		}
+		assertStatus(t, post(token, "key-running", body), http.StatusCreated)
+	})
+
+	t.Run("client errors are replayed", func(t *testing.T) {
+		invalid := `{"name":"x"}`
+		assertStatus(t, post(token, "key-invalid", invalid), http.StatusBadRequest)
+		rec := post(token, "key-invalid", invalid)
+		assertStatus(t, rec, http.StatusBadRequest)
+		if rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatal("client error was not replayed")
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		before := countEvents()
+		ta.store.mu.Lock()
+		ta.store.idempotency[owner.Id+"/key-1"].ExpiresAt = time.Now().Add(-time.Minute)
+		ta.store.mu.Unlock()
+
+		rec := post(token, "key-1", body)
+		assertStatus(t, rec, http.StatusCreated)
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatal("expired response was replayed")
+		}
+		if n := countEvents(); n != before+1 {
+			t.Fatalf("owner has %d events, want %d", n, before+1)
+		}
+	})
+}
+
+func TestIdempotentAddAttendee(t *testing.T) {
+	ta := newTestApp(t)
+	owner, token := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/events/"+event.Id+"/attendees/"+guest.Id, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", "rsvp-1")
+		rec := httptest.NewRecorder()
+		ta.handler.ServeHTTP(rec, req)
+		ta.wg.Wait()
+		return rec
+	}
+
+	assertStatus(t, post(), http.StatusCreated)
+	// Without the key the retry would be rejected as a duplicate RSVP.
+	rec := post()
+	assertStatus(t, rec, http.StatusCreated)
+	if rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("retry was not replayed")
+	}
+}
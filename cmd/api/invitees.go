@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// checkCanView reports whether user may see event, writing a 404 otherwise
+// so that hidden events are indistinguishable from missing ones. Owners see
+// everything; drafts are private to them. Public and unlisted events are
+// open to anyone with the link, private ones to invitees and attendees. user
+// is empty for anonymous requests.
+func (app *application) checkCanView(c *gin.Context, event *database.Event, user *database.User) bool {
+	if user.Id != "" && event.OwnerId == user.Id {
+		return true
+	}
+	if event.Status == database.EventDraft {
+		app.errorResponse(c, errs.NotFound("Event not found"))
+		return false
+	}
+	if event.Visibility != database.VisibilityPrivate {
+		return true
+	}
+
+	if user.Id != "" {
+		involved, err := app.models.Invitees.IsInvolved(c.Request.Context(), event.Id, user.Id)
+		if err != nil {
+			app.errorResponse(c, err)
+			return false
+		}
+		if involved {
+			return true
+		}
+	}
+	app.errorResponse(c, errs.NotFound("Event not found"))
+	return false
+}
+
+// ownedEvent loads the event named in the path and checks that user owns
+// it, writing the error response and reporting false otherwise.
+func (app *application) ownedEvent(c *gin.Context, user *database.User, forbidden string) (*database.Event, bool) {
+	event, err := app.models.Events.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return nil, false
+	}
+	if event.OwnerId != user.Id {
+		app.errorResponse(c, errs.Forbidden(forbidden))
+		return nil, false
+	}
+	return event, true
+}
+
+// getInvitees lists the users invited to an event
+//
+// @Summary Get invitees of an event
+// @Description List the users invited to an event. Only the owner can see them.
+// @Tags invitees
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {array} database.User
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/invitees [get]
+// @Security BearerAuth
+func (app *application) getInvitees(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to view the invitees of this event")
+	if !ok {
+		return
+	}
+
+	users, err := app.models.Invitees.GetByEventId(c.Request.Context(), event.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// addInvitee gives a user access to an event
+//
+// @Summary Invite a user to an event
+// @Description Invite a user to an event. Invitees can see private events.
+// @Tags invitees
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param userId path string true "User ID to invite"
+// @Success 201 {object} map[string]string
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/invitees/{userId} [post]
+// @Security BearerAuth
+func (app *application) addInvitee(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to invite users to this event")
+	if !ok {
+		return
+	}
+
+	invitee, err := app.models.Users.GetById(c.Request.Context(), c.Param("userId"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	err = app.models.Invitees.Insert(c.Request.Context(), event.Id, invitee.Id)
+	if errors.Is(err, database.ErrDuplicate) {
+		app.errorResponse(c, errs.Conflict("User is already invited to this event"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "User invited to event successfully"})
+}
+
+// removeInvitee withdraws an invitation
+//
+// @Summary Remove an invitee from an event
+// @Description Withdraw a user's invitation to an event
+// @Tags invitees
+// @Param id path string true "Event ID"
+// @Param userId path string true "User ID to remove"
+// @Success 204 {object} nil
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/events/{id}/invitees/{userId} [delete]
+// @Security BearerAuth
+func (app *application) removeInvitee(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	event, ok := app.ownedEvent(c, user, "You are not authorized to remove invitees from this event")
+	if !ok {
+		return
+	}
+
+	removed, err := app.models.Invitees.Delete(c.Request.Context(), event.Id, c.Param("userId"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if !removed {
+		app.errorResponse(c, errs.NotFound("Invitee not found"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/mergepatch"
+)
+
+func TestEventVisibility(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	invitee, inviteeToken := ta.createUser(t, "Invitee", "invitee@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	unlisted := ta.createEvent(t, owner.Id, "Unlisted meetup")
+	private := ta.createEvent(t, owner.Id, "Private meetup")
+	for event, visibility := range map[*database.Event]string{unlisted: database.VisibilityUnlisted, private: database.VisibilityPrivate} {
+		body := validEventBody()
+		body["visibility"] = visibility
+		rec := ta.do(t, http.MethodPut, "/api/v1/events/"+event.Id, body, ownerToken)
+		assertStatus(t, rec, http.StatusOK)
+		if got := decode[database.Event](t, rec); got.Visibility != visibility {
+			t.Fatalf("visibility = %q, want %q", got.Visibility, visibility)
+		}
+	}
+	ta.addAttendee(t, private.Id, guest.Id)
+	rec := ta.do(t, http.MethodPost, "/api/v1/events/"+private.Id+"/invitees/"+invitee.Id, nil, ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+
+	tests := []struct {
+		name       string
+		event      *database.Event
+		token      string
+		wantStatus int
+	}{
+		{"unlisted by link", unlisted, "", http.StatusOK},
+		{"private anonymous", private, "", http.StatusNotFound},
+		{"private other user", private, otherToken, http.StatusNotFound},
+		{"private owner", private, ownerToken, http.StatusOK},
+		{"private invitee", private, inviteeToken, http.StatusOK},
+		{"private attendee", private, guestToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodGet, "/api/v1/events/"+tt.event.Id, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+		})
+	}
+
+	listed := func(token string) map[string]bool {
+		t.Helper()
+		rec := ta.do(t, http.MethodGet, "/api/v1/events", nil, token)
+		ids := map[string]bool{}
+		if rec.Code == http.StatusNotFound {
+			return ids
+		}
+		assertStatus(t, rec, http.StatusOK)
+		for _, e := range decode[[]database.Event](t, rec) {
+			ids[e.Id] = true
+		}
+		return ids
+	}
+	if ids := listed(""); ids[unlisted.Id] || ids[private.Id] {
+		t.Fatalf("anonymous listing = %v, want neither unlisted nor private event", ids)
+	}
+	if ids := listed(inviteeToken); ids[unlisted.Id] || !ids[private.Id] {
+		t.Fatalf("invitee listing = %v, want the private event only", ids)
+	}
+	if ids := listed(ownerToken); !ids[unlisted.Id] || !ids[private.Id] {
+		t.Fatalf("owner listing = %v, want both events", ids)
+	}
+
+	// Events of deleted accounts have no owner, which must not make an
+	// anonymous caller their owner.
+	ta.store.mu.Lock()
+	ta.store.events[private.Id].OwnerId = ""
+	ta.store.mu.Unlock()
+	rec = ta.do(t, http.MethodGet, "/api/v1/events/"+private.Id, nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	ta.store.mu.Lock()
+	ta.store.events[private.Id].OwnerId = owner.Id
+	ta.store.mu.Unlock()
+
+	body := validEventBody()
+	body["visibility"] = "secret"
+	rec = ta.do(t, http.MethodPut, "/api/v1/events/"+unlisted.Id, body, ownerToken)
+	assertStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestInvitees(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	invitee, inviteeToken := ta.createUser(t, "Invitee", "invitee@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	path := "/api/v1/events/" + event.Id + "/invitees"
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"unauthenticated", http.MethodPost, path + "/" + invitee.Id, "", http.StatusUnauthorized},
+		{"not the owner", http.MethodPost, path + "/" + invitee.Id, inviteeToken, http.StatusForbidden},
+		{"unknown user", http.MethodPost, path + "/missing", ownerToken, http.StatusNotFound},
+		{"invite", http.MethodPost, path + "/" + invitee.Id, ownerToken, http.StatusCreated},
+		{"invite twice", http.MethodPost, path + "/" + invitee.Id, ownerToken, http.StatusConflict},
+		{"list not the owner", http.MethodGet, path, inviteeToken, http.StatusForbidden},
+		{"list", http.MethodGet, path, ownerToken, http.StatusOK},
+		{"remove", http.MethodDelete, path + "/" + invitee.Id, ownerToken, http.StatusNoContent},
+		{"remove twice", http.MethodDelete, path + "/" + invitee.Id, ownerToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, tt.method, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.method == http.MethodGet && tt.wantStatus == http.StatusOK {
+				users := decode[[]database.User](t, rec)
+				if len(users) != 1 || users[0].Id != invitee.Id {
+					t.Fatalf("invitees = %+v, want %s", users, invitee.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestAttendeeListPrivacy(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, guestToken := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/events/"+event.Id, strings.NewReader(`{"attendeesPublic":false}`))
+	req.Header.Set("Content-Type", mergepatch.ContentType)
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	rec := httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	assertStatus(t, rec, http.StatusOK)
+	if got := decode[database.Event](t, rec); got.AttendeesPublic {
+		t.Fatal("attendeesPublic still set after patch")
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"attendees anonymous", "/api/v1/events/" + event.Id + "/attendees", "", http.StatusForbidden},
+		{"attendees attendee", "/api/v1/events/" + event.Id + "/attendees", guestToken, http.StatusForbidden},
+		{"attendees owner", "/api/v1/events/" + event.Id + "/attendees", ownerToken, http.StatusOK},
+		{"attendance anonymous", "/api/v1/attendees/" + guest.Id + "/events", "", http.StatusNotFound},
+		{"attendance self", "/api/v1/attendees/" + guest.Id + "/events", guestToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodGet, tt.path, nil, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/tasks"
+)
+
+// queuedJobs returns the jobs of the given kind, oldest first.
+func (ta *testApp) queuedJobs(kind string) []database.Job {
+	ta.store.mu.Lock()
+	defer ta.store.mu.Unlock()
+	var jobs []database.Job
+	for _, j := range ta.store.jobs {
+		if j.Kind == kind {
+			jobs = append(jobs, j.Job)
+		}
+	}
+	return jobs
+}
+
+func TestEventReminders(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+	ta.flushOutbox()
+
+	reminders := ta.queuedJobs(tasks.SendReminder{}.Kind())
+	if len(reminders) != 1 {
+		t.Fatalf("queued %d reminders, want 1", len(reminders))
+	}
+	first := reminders[0]
+	date, _ := time.Parse("02/01/2006", futureDate())
+	if want := date.Add(-ta.config.Events.ReminderLead); !first.RunAt.Equal(want) {
+		t.Fatalf("reminder runs at %v, want %v", first.RunAt, want)
+	}
+
+	// Saving the event again does not queue another reminder for the same
+	// date; moving it does.
+	path := "/api/v1/events/" + event.Id
+	body := validEventBody()
+	body["name"] = "Renamed"
+	assertStatus(t, ta.do(t, http.MethodPut, path, body, ownerToken), http.StatusOK)
+	ta.flushOutbox()
+	if n := len(ta.queuedJobs(tasks.SendReminder{}.Kind())); n != 1 {
+		t.Fatalf("queued %d reminders after a rename, want 1", n)
+	}
+	body["date"] = time.Now().AddDate(0, 2, 0).Format("02/01/2006")
+	assertStatus(t, ta.do(t, http.MethodPut, path, body, ownerToken), http.StatusOK)
+	ta.flushOutbox()
+	reminders = ta.queuedJobs(tasks.SendReminder{}.Kind())
+	if len(reminders) != 2 {
+		t.Fatalf("queued %d reminders after moving the event, want 2", len(reminders))
+	}
+
+	if n := ta.runJobs(time.Now()); n != 0 {
+		t.Fatalf("ran %d jobs before any reminder was due", n)
+	}
+
+	// The reminder for the old date is dropped.
+	ta.runJobs(first.RunAt)
+	if sent := ta.mail.all(); len(sent) != 0 {
+		t.Fatalf("sent %+v for the old date", sent)
+	}
+
+	ta.runJobs(reminders[1].RunAt)
+	sent := ta.mail.all()
+	if len(sent) != 1 || sent[0].Recipient != guest.Email || sent[0].Subject != "Reminder: Renamed" {
+		t.Fatalf("sent %+v, want one reminder to %s", sent, guest.Email)
+	}
+	for _, job := range ta.queuedJobs(tasks.SendReminder{}.Kind()) {
+		if job.Status != database.JobSucceeded {
+			t.Fatalf("reminder job = %+v, want succeeded", job)
+		}
+	}
+}
+
+func TestRemindersSkipDrafts(t *testing.T) {
+	ta := newTestApp(t)
+	_, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+	event := decode[database.Event](t, rec)
+	ta.flushOutbox()
+	if n := len(ta.queuedJobs(tasks.SendReminder{}.Kind())); n != 0 {
+		t.Fatalf("queued %d reminders for a draft", n)
+	}
+
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events/"+event.Id+"/publish", nil, ownerToken), http.StatusOK)
+	ta.flushOutbox()
+	if n := len(ta.queuedJobs(tasks.SendReminder{}.Kind())); n != 1 {
+		t.Fatalf("queued %d reminders after publishing, want 1", n)
+	}
+}
+
+func TestPurgeJob(t *testing.T) {
+	ta := newTestApp(t)
+	user, _ := ta.createUser(t, "Jane", "jane@example.com", "password123")
+
+	path := filepath.Join(ta.config.Export.Dir, "expired.zip")
+	if err := os.WriteFile(path, []byte("zip"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expired := &database.Export{UserId: user.Id, Status: database.ExportCompleted, FilePath: path, ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := ta.models.Exports.Insert(context.Background(), expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every process schedules the purge on start, but only one is queued.
+	for range 2 {
+		if err := ta.tasks.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := ta.runJobs(time.Now()); n != 1 {
+		t.Fatalf("ran %d jobs, want one purge", n)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expired export file still exists: %v", err)
+	}
+	if _, err := ta.models.Exports.Get(context.Background(), expired.Id); err == nil {
+		t.Fatal("expired export was not deleted")
+	}
+
+	// The purge queues the next one.
+	purges := ta.queuedJobs(tasks.Purge{}.Kind())
+	if len(purges) != 2 || purges[1].Status != database.JobPending || !purges[1].RunAt.After(time.Now()) {
+		t.Fatalf("purges = %+v, want the next one queued", purges)
+	}
+}
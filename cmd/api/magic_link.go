@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+type MagicLinkConsumeRequest struct {
+	Token string `json:"token" binding:"required,len=26"`
+}
+
+const magicLinkSentMessage = "If an account exists for this email, a login link has been sent"
+
+// requestMagicLink godoc
+// @Summary Request a magic login link
+// @Description Email a single-use, short-lived login link. The response is the same whether or not the account exists.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param   request body MagicLinkRequest true "Email to send the link to"
+// @Success 202 {object} map[string]interface{} "Accepted"
+// @Failure 400 {object} Problem "Bad Request"
+// @Failure 429 {object} Problem "Too Many Requests"
+// @Router /api/v1/auth/magic-link [post]
+func (app *application) requestMagicLink(c *gin.Context) {
+	var request MagicLinkRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	// Everything after validation happens in the background so neither the
+	// status code nor the response time reveals whether the account exists.
+	if app.magicLinkEmailLimiter.allow(strings.ToLower(request.Email)) {
+		ctx := context.WithoutCancel(c.Request.Context())
+		app.background(func() {
+			user, err := app.models.Users.GetByEmail(ctx, request.Email)
+			if errs.Is(err, errs.KindNotFound) {
+				return
+			}
+			if err != nil {
+				app.logger.ErrorContext(ctx, "magic link: failed to look up user", "error", err)
+				return
+			}
+
+			token, err := app.models.Tokens.New(ctx, user.Id, app.config.MagicLink.TTL, database.ScopeMagicLink)
+			if err != nil {
+				app.logger.ErrorContext(ctx, "magic link: failed to create token", "error", err)
+				return
+			}
+
+			link := app.config.MagicLink.URL + "?token=" + url.QueryEscape(token.Plaintext)
+			body := fmt.Sprintf("Hi %s,\n\nUse the link below to log in. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not request this email you can safely ignore it.\n",
+				user.Name, int(app.config.MagicLink.TTL/time.Minute), link)
+			if err := app.mailer.Send(user.Email, "Your login link", body); err != nil {
+				app.logger.ErrorContext(ctx, "magic link: failed to send email", "error", err)
+			}
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": magicLinkSentMessage})
+}
+
+// consumeMagicLink godoc
+// @Summary Log in with a magic link
+// @Description Exchange a magic link token for a JWT token. Each token can only be used once.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param   request body MagicLinkConsumeRequest true "Token from the magic link"
+// @Success 200 {object} LoginUserResponse "Successfully authenticated"
+// @Failure 400 {object} Problem "Bad Request"
+// @Failure 401 {object} Problem "Unauthorized"
+// @Failure 429 {object} Problem "Too Many Requests"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Router /api/v1/auth/magic-link/consume [post]
+func (app *application) consumeMagicLink(c *gin.Context) {
+	var request MagicLinkConsumeRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	token, err := app.models.Tokens.Consume(c.Request.Context(), database.ScopeMagicLink, request.Token)
+	if errs.Is(err, errs.KindNotFound) {
+		app.metrics.LoginFailed("magic-link")
+		app.errorResponse(c, errs.Unauthorized("Invalid or expired login link"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	user, err := app.models.Users.GetById(c.Request.Context(), token.UserId)
+	if errs.Is(err, errs.KindNotFound) {
+		app.metrics.LoginFailed("magic-link")
+		app.errorResponse(c, errs.Unauthorized("Invalid or expired login link"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.models.Tokens.DeleteAllForUser(c.Request.Context(), database.ScopeMagicLink, user.Id); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	tokenString, err := app.issueToken(user)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	app.metrics.LoginSucceeded("magic-link")
+	c.JSON(http.StatusOK, LoginUserResponse{Token: tokenString})
+}
@@ -1,66 +1,190 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/logger"
+	"github.com/davidcm146/event-rest-api/internal/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 )
 
-func (app *application) authMiddleware() gin.HandlerFunc {
+// validRequestID restricts client supplied request IDs to something safe to
+// log and echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestID honours an incoming X-Request-ID header or generates a new ID,
+// echoes it in the response and attaches it (and the route) to the request
+// context so every log line and audit entry for the request carries them.
+func (app *application) requestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
-			return
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				id = fmt.Sprintf("%d", time.Now().UnixNano())
+			} else {
+				id = hex.EncodeToString(b)
+			}
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token is required"})
-			c.Abort()
-			return
+		c.Set("requestId", id)
+		c.Header("X-Request-ID", id)
+		if traceId := tracing.TraceID(c.Request.Context()); traceId != "" {
+			c.Header("X-Trace-ID", traceId)
 		}
+		ctx := logger.With(c.Request.Context(),
+			slog.String("request_id", id),
+			slog.String("route", c.FullPath()),
+		)
+		ctx = database.WithAuditInfo(ctx, database.AuditInfo{RequestId: id, IP: c.ClientIP()})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(app.jwtSecret), nil
-		})
+// logRequest writes one structured access log line per request.
+func (app *application) logRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
 
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
+		level := slog.LevelInfo
+		switch {
+		case c.Writer.Status() >= 500:
+			level = slog.LevelError
+		case c.Writer.Status() >= 400:
+			level = slog.LevelWarn
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		fmt.Println("Token claims:", claims)
-		if !ok || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		app.logger.LogAttrs(c.Request.Context(), level, "request completed",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("size", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// observeRequests records request count and latency metrics per route.
+func (app *application) observeRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		app.metrics.ObserveRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
+
+// metricsAuth protects the metrics endpoint with a static bearer token when
+// it is served from the public listener.
+func (app *application) metricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := "Bearer " + app.config.Metrics.Token
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+			app.errorResponse(c, errs.Unauthorized("Invalid metrics token"))
 			return
 		}
+		c.Next()
+	}
+}
+
+// recoverPanic logs panics with the request context and responds with a 500.
+func (app *application) recoverPanic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.ErrorContext(c.Request.Context(), "panic recovered", "panic", fmt.Sprint(err))
+				app.problem(c, http.StatusInternalServerError, internalErrorDetail)
+			}
+		}()
+		c.Next()
+	}
+}
 
-		userId, ok := claims["userId"].(string)
-		fmt.Println("User ID from token:", userId)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+// authMiddleware requires a valid bearer token and stores the user it
+// belongs to in the context.
+func (app *application) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			app.errorResponse(c, errs.Unauthorized("Authorization header is required"))
 			return
 		}
+		app.authenticate(c)
+	}
+}
 
-		user, err := app.models.Users.GetById(userId)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized user"})
-			c.Abort()
+// optionalAuth identifies the user on public routes when a token is sent,
+// so they can see what only they are allowed to see, such as their drafts.
+// Anonymous requests pass through; invalid tokens are still rejected.
+func (app *application) optionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
 			return
 		}
-		c.Set("user", user)
-		c.Next()
+		app.authenticate(c)
+	}
+}
+
+// authenticate validates the bearer token and loads its user into the
+// context before continuing, or aborts with 401.
+func (app *application) authenticate(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		app.errorResponse(c, errs.Unauthorized("Bearer token is required"))
+		return
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(app.config.JWTSecret), nil
+	})
+
+	if err != nil {
+		app.errorResponse(c, errs.Unauthorized("Invalid token"))
+		return
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		app.errorResponse(c, errs.Unauthorized("Invalid token"))
+		return
+	}
+
+	userId, ok := claims["userId"].(string)
+	if !ok {
+		app.errorResponse(c, errs.Unauthorized("Invalid token claims"))
+		return
+	}
+
+	user, err := app.models.Users.GetById(c.Request.Context(), userId)
+	if errs.Is(err, errs.KindNotFound) {
+		app.errorResponse(c, errs.Unauthorized("Unauthorized user"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
 	}
+	c.Set("user", user)
+	ctx := logger.With(c.Request.Context(), slog.String("user_id", user.Id))
+	info := database.AuditInfoFrom(ctx)
+	info.ActorId = user.Id
+	c.Request = c.Request.WithContext(database.WithAuditInfo(ctx, info))
+	c.Next()
 }
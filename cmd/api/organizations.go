@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,notblank,min=2,max=100"`
+}
+
+type AddMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member" enums:"admin,member"`
+}
+
+// createOrganization creates an organization
+//
+// @Summary Create an organization
+// @Description Create an organization with yourself as its admin. Webhooks of an organization are told about changes to the events of all its members.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body CreateOrganizationRequest true "Organization"
+// @Success 201 {object} database.Organization
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/organizations [post]
+// @Security BearerAuth
+func (app *application) createOrganization(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request CreateOrganizationRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	organization := &database.Organization{Name: request.Name}
+	if err := app.models.Organizations.Insert(c.Request.Context(), organization, user.Id); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, organization)
+}
+
+// getOrganizations lists the user's organizations
+//
+// @Summary Get organizations
+// @Description List the organizations you belong to, with your role in each
+// @Tags organizations
+// @Produce json
+// @Success 200 {array} database.Organization
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/organizations [get]
+// @Security BearerAuth
+func (app *application) getOrganizations(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	organizations, err := app.models.Organizations.GetByMember(c.Request.Context(), user.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, organizations)
+}
+
+// getOrganization returns a single organization
+//
+// @Summary Get an organization
+// @Description Get an organization you belong to, with your role in it
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} database.Organization
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/organizations/{id} [get]
+// @Security BearerAuth
+func (app *application) getOrganization(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	organization, err := app.models.Organizations.Get(c.Request.Context(), c.Param("id"), user.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, organization)
+}
+
+// getOrganizationMembers lists the members of an organization
+//
+// @Summary Get organization members
+// @Description List the members of an organization you belong to
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {array} database.Member
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/organizations/{id}/members [get]
+// @Security BearerAuth
+func (app *application) getOrganizationMembers(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	organization, err := app.models.Organizations.Get(c.Request.Context(), c.Param("id"), user.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	members, err := app.models.Organizations.GetMembers(c.Request.Context(), organization.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// addOrganizationMember adds a user to an organization
+//
+// @Summary Add an organization member
+// @Description Add a user to an organization as an admin or a member. Only admins can add members.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param userId path string true "User ID to add"
+// @Param request body AddMemberRequest true "Role"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/organizations/{id}/members/{userId} [post]
+// @Security BearerAuth
+func (app *application) addOrganizationMember(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	organization, ok := app.managedOrganization(c, user, c.Param("id"), "Only admins can add members to this organization")
+	if !ok {
+		return
+	}
+
+	var request AddMemberRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	member, err := app.models.Users.GetById(c.Request.Context(), c.Param("userId"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	err = app.models.Organizations.AddMember(c.Request.Context(), organization.Id, member.Id, request.Role)
+	if errors.Is(err, database.ErrDuplicate) {
+		app.errorResponse(c, errs.Conflict("User is already a member of this organization"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "User added to organization successfully"})
+}
+
+// removeOrganizationMember takes a user out of an organization
+//
+// @Summary Remove an organization member
+// @Description Remove a member from an organization. Admins can remove anyone and members can leave; the last admin cannot be removed.
+// @Tags organizations
+// @Param id path string true "Organization ID"
+// @Param userId path string true "User ID to remove"
+// @Success 204 {object} nil
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/organizations/{id}/members/{userId} [delete]
+// @Security BearerAuth
+func (app *application) removeOrganizationMember(c *gin.Context) {
+	user := app.getUserFromContext(c)
+	organization, err := app.models.Organizations.Get(c.Request.Context(), c.Param("id"), user.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if c.Param("userId") != user.Id && organization.Role != database.OrganizationAdmin {
+		app.errorResponse(c, errs.Forbidden("Only admins can remove members from this organization"))
+		return
+	}
+
+	removed, err := app.models.Organizations.RemoveMember(c.Request.Context(), organization.Id, c.Param("userId"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if !removed {
+		app.errorResponse(c, errs.NotFound("Member not found"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// managedOrganization loads an organization and checks that user is one of
+// its admins, writing the error response and reporting false otherwise.
+// Organizations the user does not belong to are reported as missing.
+func (app *application) managedOrganization(c *gin.Context, user *database.User, id, forbidden string) (*database.Organization, bool) {
+	organization, err := app.models.Organizations.Get(c.Request.Context(), id, user.Id)
+	if err == nil && organization.Role != database.OrganizationAdmin {
+		err = errs.Forbidden(forbidden)
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return nil, false
+	}
+	return organization, true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestOrganizations(t *testing.T) {
+	ta := newTestApp(t)
+	admin, adminToken := ta.createUser(t, "Admin", "admin@example.com", "password123")
+	member, memberToken := ta.createUser(t, "Member", "member@example.com", "password123")
+	other, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/organizations", map[string]any{"name": "Go Hanoi"}, adminToken)
+	assertStatus(t, rec, http.StatusCreated)
+	org := decode[database.Organization](t, rec)
+	if org.Role != database.OrganizationAdmin {
+		t.Fatalf("creator role = %q, want %q", org.Role, database.OrganizationAdmin)
+	}
+	path := "/api/v1/organizations/" + org.Id
+	memberPath := func(userId string) string { return path + "/members/" + userId }
+	asMember := map[string]any{"role": database.OrganizationMember}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		token      string
+		wantStatus int
+	}{
+		{"create without a name", http.MethodPost, "/api/v1/organizations", map[string]any{"name": " "}, adminToken, http.StatusBadRequest},
+		{"get not a member", http.MethodGet, path, nil, otherToken, http.StatusNotFound},
+		{"get missing", http.MethodGet, "/api/v1/organizations/missing", nil, adminToken, http.StatusNotFound},
+		{"add with unknown role", http.MethodPost, memberPath(member.Id), map[string]any{"role": "owner"}, adminToken, http.StatusBadRequest},
+		{"add unknown user", http.MethodPost, memberPath("missing"), asMember, adminToken, http.StatusNotFound},
+		{"add by an outsider", http.MethodPost, memberPath(other.Id), asMember, otherToken, http.StatusNotFound},
+		{"add", http.MethodPost, memberPath(member.Id), asMember, adminToken, http.StatusCreated},
+		{"add twice", http.MethodPost, memberPath(member.Id), asMember, adminToken, http.StatusConflict},
+		{"get as a member", http.MethodGet, path, nil, memberToken, http.StatusOK},
+		{"members as a member", http.MethodGet, path + "/members", nil, memberToken, http.StatusOK},
+		{"add by a member", http.MethodPost, memberPath(other.Id), asMember, memberToken, http.StatusForbidden},
+		{"remove by a member", http.MethodDelete, memberPath(admin.Id), nil, memberToken, http.StatusForbidden},
+		{"remove the last admin", http.MethodDelete, memberPath(admin.Id), nil, adminToken, http.StatusConflict},
+		{"remove a non-member", http.MethodDelete, memberPath(other.Id), nil, adminToken, http.StatusNotFound},
+		{"leave", http.MethodDelete, memberPath(member.Id), nil, memberToken, http.StatusNoContent},
+		{"get after leaving", http.MethodGet, path, nil, memberToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, tt.method, tt.path, tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus >= http.StatusBadRequest {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/organizations", nil, adminToken)
+	assertStatus(t, rec, http.StatusOK)
+	if orgs := decode[[]database.Organization](t, rec); len(orgs) != 1 || orgs[0].Id != org.Id || orgs[0].Role != database.OrganizationAdmin {
+		t.Fatalf("organizations = %+v, want the one created", orgs)
+	}
+	rec = ta.do(t, http.MethodGet, "/api/v1/organizations", nil, memberToken)
+	assertStatus(t, rec, http.StatusOK)
+	if orgs := decode[[]database.Organization](t, rec); len(orgs) != 0 {
+		t.Fatalf("organizations = %+v, want none after leaving", orgs)
+	}
+	rec = ta.do(t, http.MethodGet, path+"/members", nil, adminToken)
+	assertStatus(t, rec, http.StatusOK)
+	if members := decode[[]database.Member](t, rec); len(members) != 1 || members[0].UserId != admin.Id || members[0].Email != admin.Email {
+		t.Fatalf("members = %+v, want the admin only", members)
+	}
+}
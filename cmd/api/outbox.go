@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+// outboxBatchSize is the number of outbox messages claimed in one round.
+const outboxBatchSize = 50
+
+// subscriber receives the outbox messages of some topics. Delivery is at
+// least once: a message is handed over again if relaying it is interrupted,
+// so handlers use the message id to drop duplicates where that matters.
+type subscriber struct {
+	name   string
+	topics []string
+	handle func(ctx context.Context, msg *database.OutboxMessage) error
+}
+
+// outboxSubscribers returns the in-process consumers of domain events.
+func (app *application) outboxSubscribers() []subscriber {
+	return []subscriber{
+		{
+			name:   "mailer",
+			topics: []string{database.TopicEventCancelled, database.TopicEventDeleted},
+			handle: app.mailCancellation,
+		},
+		{
+			name:   "reminders",
+			topics: []string{database.TopicEventCreated, database.TopicEventUpdated, database.TopicEventPublished, database.TopicEventRestored},
+			handle: app.scheduleReminder,
+		},
+		{
+			name:   "webhooks",
+			topics: slices.Collect(maps.Keys(webhookTopics)),
+			handle: app.queueWebhooks,
+		},
+	}
+}
+
+// relayOutbox hands new outbox messages to their subscribers every
+// PollInterval, and deletes relayed messages once they are older than the
+// retention period, until ctx is cancelled.
+func (app *application) relayOutbox(ctx context.Context) {
+	ticker := time.NewTicker(app.config.Outbox.PollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		for app.relayOutboxOnce(ctx, time.Now()) == outboxBatchSize && ctx.Err() == nil {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			app.deletePublishedMessages(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayOutboxOnce relays one batch of messages that are due at now, oldest
+// first, and reports how many it claimed.
+func (app *application) relayOutboxOnce(ctx context.Context, now time.Time) int {
+	messages, err := app.models.Outbox.ClaimPending(ctx, now, time.Minute, outboxBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			app.logger.ErrorContext(ctx, "failed to claim outbox messages", "error", err)
+		}
+		return 0
+	}
+	for _, msg := range messages {
+		app.relayMessage(context.WithoutCancel(ctx), msg, now)
+	}
+	return len(messages)
+}
+
+// relayMessage hands msg to every subscriber of its topic that has not
+// handled it yet. If any of them fails, the message is retried later with
+// exponential backoff, skipping the subscribers that succeeded.
+func (app *application) relayMessage(ctx context.Context, msg *database.OutboxMessage, now time.Time) {
+	var failures []error
+	for _, sub := range app.subscribers {
+		if !slices.Contains(sub.topics, msg.Topic) || slices.Contains(msg.Handled, sub.name) {
+			continue
+		}
+		if err := sub.handle(ctx, msg); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", sub.name, err))
+			continue
+		}
+		if err := app.models.Outbox.MarkHandled(ctx, msg.Id, sub.name); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+
+	if len(failures) == 0 {
+		if err := app.models.Outbox.MarkPublished(ctx, msg.Id); err != nil {
+			app.logger.ErrorContext(ctx, "failed to mark outbox message as published", "message_id", msg.Id, "error", err)
+		}
+		return
+	}
+
+	err := errors.Join(failures...)
+	app.logger.WarnContext(ctx, "failed to relay outbox message", "message_id", msg.Id, "topic", msg.Topic, "attempts", msg.Attempts+1, "error", err)
+	if err := app.models.Outbox.Retry(ctx, msg.Id, now.Add(outboxBackoff(msg.Attempts+1)), err.Error()); err != nil {
+		app.logger.ErrorContext(ctx, "failed to reschedule outbox message", "message_id", msg.Id, "error", err)
+	}
+}
+
+// outboxBackoff is how long to wait before relaying a message again after
+// attempts failures: one second, doubling every time, capped at ten minutes.
+func outboxBackoff(attempts int) time.Duration {
+	const limit = 10 * time.Minute
+	if attempts > 10 {
+		return limit
+	}
+	return min(time.Second<<max(attempts-1, 0), limit)
+}
+
+func (app *application) deletePublishedMessages(ctx context.Context) {
+	deleted, err := app.models.Outbox.DeletePublished(ctx, time.Now().Add(-app.config.Outbox.Retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			app.logger.ErrorContext(ctx, "failed to delete relayed outbox messages", "error", err)
+		}
+		return
+	}
+	if deleted > 0 {
+		app.logger.InfoContext(ctx, "deleted relayed outbox messages", "count", deleted)
+	}
+}
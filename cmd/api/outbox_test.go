@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+// outboxTopics returns the topics in the outbox and whether each message has
+// been published.
+func (ta *testApp) outboxTopics() ([]string, []bool) {
+	ta.store.mu.Lock()
+	defer ta.store.mu.Unlock()
+	var topics []string
+	var published []bool
+	for _, msg := range ta.store.outbox {
+		topics = append(topics, msg.Topic)
+		published = append(published, msg.publishedAt != nil)
+	}
+	return topics, published
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+	path := "/api/v1/events/" + event.Id
+
+	// A change that is rejected announces nothing.
+	req := httptest.NewRequest(http.MethodDelete, path, nil)
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	req.Header.Set("If-Match", `"99"`)
+	rec := httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	assertStatus(t, rec, http.StatusPreconditionFailed)
+
+	assertStatus(t, ta.do(t, http.MethodDelete, path, nil, ownerToken), http.StatusNoContent)
+	if len(ta.mail.all()) != 0 {
+		t.Fatal("email sent before the outbox was relayed")
+	}
+
+	topics, _ := ta.outboxTopics()
+	want := []string{database.TopicEventCreated, database.TopicAttendeeAdded, database.TopicEventDeleted}
+	if !slices.Equal(topics, want) {
+		t.Fatalf("outbox topics = %v, want %v", topics, want)
+	}
+
+	ta.flushOutbox()
+	if sent := ta.mail.all(); len(sent) != 1 || sent[0].Recipient != guest.Email {
+		t.Fatalf("sent %+v, want one cancellation email to %s", sent, guest.Email)
+	}
+	if _, published := ta.outboxTopics(); slices.Contains(published, false) {
+		t.Fatalf("published = %v, want every message published", published)
+	}
+
+	// Published messages are not relayed again.
+	ta.flushOutbox()
+	if sent := ta.mail.all(); len(sent) != 1 {
+		t.Fatalf("sent %d emails after relaying twice, want 1", len(sent))
+	}
+}
+
+func TestOutboxRetriesFailedSubscribers(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+	ta.flushOutbox()
+
+	var seen []string
+	ta.subscribers = append(ta.subscribers, subscriber{
+		name:   "flaky",
+		topics: []string{database.TopicEventCancelled},
+		handle: func(ctx context.Context, msg *database.OutboxMessage) error {
+			seen = append(seen, msg.Id)
+			if len(seen) == 1 {
+				return errors.New("temporarily unavailable")
+			}
+			return nil
+		},
+	})
+
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events/"+event.Id+"/cancel", map[string]any{"reason": "Rain"}, ownerToken), http.StatusOK)
+
+	now := time.Now()
+	ta.relayOutboxOnce(context.Background(), now)
+	msg := ta.store.outbox[len(ta.store.outbox)-1]
+	if msg.publishedAt != nil || msg.Attempts != 1 || msg.lastError == "" || !msg.nextAttemptAt.Equal(now.Add(outboxBackoff(1))) {
+		t.Fatalf("message = %+v, want a retry scheduled after the failure", msg)
+	}
+
+	// Not due yet.
+	if n := ta.relayOutboxOnce(context.Background(), now); n != 0 {
+		t.Fatalf("claimed %d messages before the backoff elapsed", n)
+	}
+
+	ta.relayOutboxOnce(context.Background(), now.Add(time.Minute))
+	if msg.publishedAt == nil || len(seen) != 2 || seen[0] != seen[1] {
+		t.Fatalf("message = %+v, seen %v, want it published on the second attempt with the same id", msg, seen)
+	}
+	// The mailer succeeded the first time and is not asked again.
+	if sent := ta.mail.all(); len(sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sent))
+	}
+}
+
+func TestQueueWebhooksIsIdempotent(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	hook, _ := ta.subscribe(t, ownerToken, database.WebhookEventCreated)
+	ta.createEvent(t, owner.Id, "Go meetup")
+
+	messages, err := ta.models.Outbox.ClaimPending(context.Background(), time.Now(), time.Minute, 1)
+	if err != nil || len(messages) != 1 || messages[0].Topic != database.TopicEventCreated {
+		t.Fatalf("ClaimPending() = %v, %v", messages, err)
+	}
+	for range 2 {
+		if err := ta.queueWebhooks(context.Background(), messages[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, _ := ta.models.Webhooks.GetDeliveries(context.Background(), hook.Id, 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries for one message, want 1", len(deliveries))
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{6, 32 * time.Second},
+		{10, 512 * time.Second},
+		{11, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
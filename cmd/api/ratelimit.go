@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimiter is a fixed-window, in-memory limiter keyed by an arbitrary
+// string (client IP, email address, ...).
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+}
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+	}
+}
+
+func (rl *rateLimiter) allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	w, exists := rl.clients[key]
+	if !exists || now.After(w.reset) {
+		w = &rateWindow{reset: now.Add(rl.window)}
+		rl.clients[key] = w
+	}
+	if w.count >= rl.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// sweep drops expired windows every window until ctx is cancelled, so the
+// map does not grow without bound.
+func (rl *rateLimiter) sweep(ctx context.Context) {
+	ticker := time.NewTicker(rl.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rl.mu.Lock()
+			for k, w := range rl.clients {
+				if now.After(w.reset) {
+					delete(rl.clients, k)
+				}
+			}
+			rl.mu.Unlock()
+		}
+	}
+}
+
+func (app *application) rateLimitByIP(rl *rateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rl.allow(c.ClientIP()) {
+			app.problem(c, http.StatusTooManyRequests, "Too many requests, please try again later")
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,43 +1,121 @@
 package main
 
 import (
+	"net/http"
+	"net/url"
+
+	"github.com/davidcm146/event-rest-api/docs"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"net/http"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func (app *application) routes() http.Handler {
-	g := gin.Default()
+	g := gin.New()
+	if err := g.SetTrustedProxies(app.config.TrustedProxies); err != nil {
+		app.logger.Error("invalid trusted proxies, trusting none", "error", err)
+		g.SetTrustedProxies(nil)
+	}
+	g.Use(
+		otelgin.Middleware(app.config.Tracing.ServiceName),
+		app.requestID(),
+		app.logRequest(),
+		app.observeRequests(),
+		app.recoverPanic(),
+	)
+	g.HandleMethodNotAllowed = true
+	g.NoRoute(func(c *gin.Context) {
+		app.problem(c, http.StatusNotFound, "The requested resource could not be found")
+	})
+	g.NoMethod(func(c *gin.Context) {
+		app.problem(c, http.StatusMethodNotAllowed, "The method is not supported for this resource")
+	})
+
+	// Without a dedicated listen address, metrics are only exposed on the
+	// public port when protected by a token.
+	if app.config.Metrics.Addr == "" && app.config.Metrics.Token != "" {
+		g.GET("/metrics", app.metricsAuth(), gin.WrapH(app.metrics.Handler()))
+	}
+
+	g.GET("/healthz", app.healthz)
+	g.GET("/readyz", app.readyz)
 
 	v1 := g.Group("/api/v1")
 	{
-		v1.GET("/events", app.getAllEvents)
-		v1.GET("/events/:id", app.getEvent)
-		v1.GET("/events/:id/attendees", app.getAttendeesByEvent)
-		v1.GET("/attendees/:id/events", app.getEventsByAttendee)
+		v1.GET("/events", app.optionalAuth(), app.getAllEvents)
+		v1.GET("/events/:id", app.optionalAuth(), app.getEvent)
+		v1.GET("/events/:id/attendees", app.optionalAuth(), app.getAttendeesByEvent)
+		v1.GET("/attendees/:id/events", app.optionalAuth(), app.getEventsByAttendee)
+		v1.GET("/exports/:id/download", app.downloadExport)
 
 		v1.POST("/auth/register", app.registerUser)
 		v1.POST("/auth/login", app.loginUser)
+		v1.POST("/auth/magic-link", app.rateLimitByIP(app.magicLinkIPLimiter), app.requestMagicLink)
+		v1.POST("/auth/magic-link/consume", app.rateLimitByIP(app.magicLinkConsumeIPLimiter), app.consumeMagicLink)
 	}
 
 	authGroup := v1.Group("/")
 	authGroup.Use(app.authMiddleware())
 	{
-		authGroup.POST("/events", app.createEvent)
+		authGroup.POST("/events", app.idempotent(), app.createEvent)
 		authGroup.PUT("/events/:id", app.updateEvent)
-		authGroup.POST("/events/:id/attendees/:userId", app.addAttendeeToEvent)
+		authGroup.PATCH("/events/:id", app.patchEvent)
+		authGroup.POST("/events/:id/attendees/:userId", app.idempotent(), app.addAttendeeToEvent)
 		authGroup.DELETE("/events/:id", app.deleteEvent)
+		authGroup.POST("/events/:id/restore", app.restoreEvent)
+		authGroup.POST("/events/:id/publish", app.publishEvent)
+		authGroup.POST("/events/:id/cancel", app.cancelEvent)
+		authGroup.POST("/events/:id/complete", app.completeEvent)
+		authGroup.GET("/events/:id/transitions", app.getEventTransitions)
+		authGroup.GET("/events/:id/revisions", app.getEventRevisions)
+		authGroup.GET("/events/:id/revisions/diff", app.diffEventRevisions)
+		authGroup.GET("/events/:id/revisions/:version", app.getEventRevision)
+		authGroup.POST("/events/:id/revisions/:version/revert", app.revertEvent)
 		authGroup.DELETE("/events/:id/attendees/:userId", app.removeAttendeeFromEvent)
+		authGroup.GET("/events/:id/invitees", app.getInvitees)
+		authGroup.POST("/events/:id/invitees/:userId", app.addInvitee)
+		authGroup.DELETE("/events/:id/invitees/:userId", app.removeInvitee)
+
+		authGroup.GET("/me", app.getCurrentUser)
+		authGroup.PATCH("/me", app.updateCurrentUser)
+		authGroup.DELETE("/me", app.deleteCurrentUser)
+		authGroup.PUT("/me/password", app.changePassword)
+		authGroup.POST("/me/email", app.requestEmailChange)
+		authGroup.POST("/me/email/confirm", app.confirmEmailChange)
+		authGroup.GET("/me/export", app.exportPersonalData)
+		authGroup.GET("/me/exports/:id", app.getExportStatus)
+		authGroup.GET("/me/deleted-events", app.getDeletedEvents)
+
+		authGroup.POST("/webhooks", app.createWebhook)
+		authGroup.GET("/webhooks", app.getWebhooks)
+		authGroup.GET("/webhooks/:id", app.getWebhook)
+		authGroup.DELETE("/webhooks/:id", app.deleteWebhook)
+		authGroup.GET("/webhooks/:id/deliveries", app.getWebhookDeliveries)
+		authGroup.POST("/webhooks/:id/deliveries/:deliveryId/replay", app.replayWebhookDelivery)
 
+		authGroup.POST("/organizations", app.createOrganization)
+		authGroup.GET("/organizations", app.getOrganizations)
+		authGroup.GET("/organizations/:id", app.getOrganization)
+		authGroup.GET("/organizations/:id/members", app.getOrganizationMembers)
+		authGroup.POST("/organizations/:id/members/:userId", app.addOrganizationMember)
+		authGroup.DELETE("/organizations/:id/members/:userId", app.removeOrganizationMember)
+
+		authGroup.GET("/admin/audit-log", app.requireAdmin(), app.getAuditLog)
 	}
 
-	g.GET("/swagger/*any", func(c *gin.Context) {
-		if c.Request.RequestURI == "/swagger/" {
-			c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
+	if app.config.SwaggerEnabled {
+		if baseURL, err := url.Parse(app.config.BaseURL); err == nil {
+			docs.SwaggerInfo.Host = baseURL.Host
 		}
-		ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("http://localhost:8080/swagger/doc.json"))(c)
-	})
+		g.GET("/swagger/*any", func(c *gin.Context) {
+			if c.Request.RequestURI == "/swagger/" {
+				c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
+				return
+			}
+			ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(app.config.BaseURL+"/swagger/doc.json"))(c)
+		})
+	}
 
 	return g
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	ta := newTestApp(t)
+
+	rec := ta.do(t, http.MethodGet, "/healthz", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+}
+
+func TestReadyz(t *testing.T) {
+	ta := newTestApp(t)
+
+	// The test database is unreachable.
+	rec := ta.do(t, http.MethodGet, "/readyz", nil, "")
+	assertStatus(t, rec, http.StatusServiceUnavailable)
+
+	ta.shuttingDown.Store(true)
+	rec = ta.do(t, http.MethodGet, "/readyz", nil, "")
+	assertStatus(t, rec, http.StatusServiceUnavailable)
+	if !strings.Contains(rec.Body.String(), "shutting down") {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ta := newTestApp(t)
+
+	rec := ta.do(t, http.MethodGet, "/metrics", nil, "")
+	assertStatus(t, rec, http.StatusUnauthorized)
+	decodeProblem(t, rec)
+
+	rec = ta.do(t, http.MethodGet, "/metrics", nil, "wrong-token")
+	assertStatus(t, rec, http.StatusUnauthorized)
+
+	rec = ta.do(t, http.MethodGet, "/metrics", nil, ta.config.Metrics.Token)
+	assertStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "http_requests_total") {
+		t.Fatal("metrics output is missing http_requests_total")
+	}
+}
+
+func TestSwagger(t *testing.T) {
+	ta := newTestApp(t)
+
+	rec := ta.do(t, http.MethodGet, "/swagger/", nil, "")
+	assertStatus(t, rec, http.StatusMovedPermanently)
+
+	rec = ta.do(t, http.MethodGet, "/swagger/doc.json", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+}
+
+func TestUnknownRoutes(t *testing.T) {
+	ta := newTestApp(t)
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/nope", nil, "")
+	assertStatus(t, rec, http.StatusNotFound)
+	decodeProblem(t, rec)
+
+	rec = ta.do(t, http.MethodPatch, "/api/v1/events", nil, "")
+	assertStatus(t, rec, http.StatusMethodNotAllowed)
+	decodeProblem(t, rec)
+}
+
+func TestRequestID(t *testing.T) {
+	ta := newTestApp(t)
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/events/missing", nil, "")
+	id := rec.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("no request ID generated")
+	}
+	if p := decodeProblem(t, rec); p.RequestId != id {
+		t.Fatalf("problem request ID %q does not match header %q", p.RequestId, id)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rec = httptest.NewRecorder()
+	ta.handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Fatalf("X-Request-ID = %q, want the incoming abc-123", got)
+	}
+}
@@ -1,21 +1,118 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+// serve runs the HTTP server until SIGINT or SIGTERM is received, then
+// drains in-flight requests and background tasks within the configured
+// shutdown timeout.
 func (app *application) serve() error {
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.port),
+		Addr:         fmt.Sprintf(":%d", app.config.Port),
 		Handler:      app.routes(),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
-	log.Printf("Starting server on port %d", app.port)
-	return server.ListenAndServe()
+	var metricsServer *http.Server
+	if app.config.Metrics.Addr != "" {
+		metricsServer = &http.Server{
+			Addr:         app.config.Metrics.Addr,
+			Handler:      app.metrics.Handler(),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		}
+		go func() {
+			app.logger.Info("starting metrics server", "addr", app.config.Metrics.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
+
+	// Background jobs stop when the server shuts down. Running jobs are
+	// allowed to finish first.
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	app.background(func() {
+		app.relayOutbox(jobs)
+	})
+	for _, rl := range []*rateLimiter{app.magicLinkIPLimiter, app.magicLinkConsumeIPLimiter, app.magicLinkEmailLimiter} {
+		app.background(func() {
+			rl.sweep(jobs)
+		})
+	}
+	if app.config.RunJobs {
+		if err := app.tasks.Start(jobs); err != nil {
+			app.logger.Error("failed to schedule recurring jobs", "error", err)
+		}
+		worker := app.tasks.Worker()
+		app.background(func() {
+			worker.Run(jobs)
+		})
+	}
+
+	shutdownError := make(chan error)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		// Fail readiness first so load balancers stop routing new traffic.
+		app.shuttingDown.Store(true)
+		app.logger.Info("shutting down server", "signal", s.String(), "timeout", app.config.ShutdownTimeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+		defer cancel()
+
+		if metricsServer != nil {
+			if err := metricsServer.Shutdown(ctx); err != nil {
+				app.logger.Error("failed to shut down metrics server", "error", err)
+			}
+		}
+
+		if err := server.Shutdown(ctx); err != nil {
+			shutdownError <- err
+			return
+		}
+
+		stopJobs()
+		app.logger.Info("waiting for background tasks to finish")
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- fmt.Errorf("background tasks did not finish: %w", ctx.Err())
+		}
+	}()
+
+	app.logger.Info("starting server", "port", app.config.Port)
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownError; err != nil {
+		return err
+	}
+
+	app.logger.Info("server stopped")
+	return nil
 }
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTTL = 24 * time.Hour
+
+type UpdateUserRequest struct {
+	Name *string `json:"name" binding:"omitempty,notblank,min=2,max=100"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=8,max=72"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required,len=26"`
+}
+
+type DeleteUserRequest struct {
+	Password    string `json:"password" binding:"required"`
+	OwnedEvents string `json:"ownedEvents" binding:"omitempty,oneof=transfer cancel"`
+	TransferTo  string `json:"transferTo" binding:"required_if=OwnedEvents transfer"`
+}
+
+// checkPassword re-authenticates the current user before a sensitive change.
+func checkPassword(user *database.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+// checkEmailAvailable returns a conflict when another account already uses
+// the address.
+func (app *application) checkEmailAvailable(ctx context.Context, email string) error {
+	_, err := app.models.Users.GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return errs.Conflict("Email is already in use")
+	case errs.Is(err, errs.KindNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// getCurrentUser godoc
+// @Summary Get current user
+// @Description Returns the profile of the authenticated user
+// @Tags Me
+// @Produce json
+// @Success 200 {object} database.User
+// @Failure 401 {object} Problem
+// @Router /api/v1/me [get]
+// @Security BearerAuth
+func (app *application) getCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, app.getUserFromContext(c))
+}
+
+// updateCurrentUser godoc
+// @Summary Update current user
+// @Description Update the profile of the authenticated user. Use the email and password endpoints to change those.
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param user body UpdateUserRequest true "Fields to update"
+// @Success 200 {object} database.User
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me [patch]
+// @Security BearerAuth
+func (app *application) updateCurrentUser(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request UpdateUserRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	if request.Name != nil {
+		user.Name = *request.Name
+	}
+
+	if err := app.models.Users.Update(c.Request.Context(), user); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// changePassword godoc
+// @Summary Change password
+// @Description Change the password of the authenticated user. The current password is required.
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param password body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me/password [put]
+// @Security BearerAuth
+func (app *application) changePassword(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request ChangePasswordRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	if !checkPassword(user, request.CurrentPassword) {
+		app.errorResponse(c, errs.Unauthorized("Current password is incorrect"))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.models.Users.UpdatePassword(c.Request.Context(), user.Id, string(hashedPassword)); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+}
+
+// requestEmailChange godoc
+// @Summary Request an email change
+// @Description Send a verification token to the new address. The email is only changed once the token is confirmed.
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param email body ChangeEmailRequest true "New email and current password"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me/email [post]
+// @Security BearerAuth
+func (app *application) requestEmailChange(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request ChangeEmailRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	if !checkPassword(user, request.Password) {
+		app.errorResponse(c, errs.Unauthorized("Current password is incorrect"))
+		return
+	}
+
+	if err := app.checkEmailAvailable(c.Request.Context(), request.Email); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	token, err := app.models.Tokens.NewWithPayload(c.Request.Context(), user.Id, emailChangeTTL, database.ScopeEmailChange, request.Email)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	app.background(func() {
+		body := fmt.Sprintf("Hi %s,\n\nPlease confirm your new email address with the token below. It expires in 24 hours.\n\n%s\n\nIf you did not request this change you can safely ignore this email.\n",
+			user.Name, token.Plaintext)
+		if err := app.mailer.Send(request.Email, "Confirm your new email address", body); err != nil {
+			app.logger.ErrorContext(ctx, "email change: failed to send email", "error", err)
+		}
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A verification token has been sent to the new email address"})
+}
+
+// confirmEmailChange godoc
+// @Summary Confirm an email change
+// @Description Apply a pending email change using the token sent to the new address
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param token body ConfirmEmailChangeRequest true "Verification token"
+// @Success 200 {object} database.User
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me/email/confirm [post]
+// @Security BearerAuth
+func (app *application) confirmEmailChange(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request ConfirmEmailChangeRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	// Only the user who asked for the change can redeem the token, so no one
+	// else can use it up.
+	token, err := app.models.Tokens.ConsumeForUser(c.Request.Context(), database.ScopeEmailChange, request.Token, user.Id)
+	if errs.Is(err, errs.KindNotFound) {
+		app.errorResponse(c, errs.Unauthorized("Invalid or expired verification token"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.checkEmailAvailable(c.Request.Context(), token.Payload); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	user.Email = token.Payload
+	err = app.models.Users.Update(c.Request.Context(), user)
+	if errors.Is(err, database.ErrDuplicate) {
+		app.errorResponse(c, errs.Conflict("Email is already in use"))
+		return
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if err := app.models.Tokens.DeleteAllForUser(c.Request.Context(), database.ScopeEmailChange, user.Id); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// deleteCurrentUser godoc
+// @Summary Delete current user
+// @Description Delete the authenticated user's account. Users who own events must choose whether to transfer them to another user or cancel them. Cancelled events are moved to the trash and their attendees are notified.
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param request body DeleteUserRequest true "Password and handling of owned events"
+// @Success 204
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/me [delete]
+// @Security BearerAuth
+func (app *application) deleteCurrentUser(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request DeleteUserRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	if !checkPassword(user, request.Password) {
+		app.errorResponse(c, errs.Unauthorized("Current password is incorrect"))
+		return
+	}
+
+	ownedEvents, err := app.models.Events.CountByOwner(c.Request.Context(), user.Id)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+
+	if ownedEvents > 0 && request.OwnedEvents == "" {
+		app.errorResponse(c, errs.Conflict(fmt.Sprintf("You own %d events. Set ownedEvents to 'transfer' or 'cancel' to delete your account", ownedEvents)))
+		return
+	}
+
+	transferTo := ""
+	if request.OwnedEvents == "transfer" {
+		if request.TransferTo == user.Id {
+			app.errorResponse(c, errs.Validation("Events cannot be transferred to yourself"))
+			return
+		}
+
+		newOwner, err := app.models.Users.GetById(c.Request.Context(), request.TransferTo)
+		if errs.Is(err, errs.KindNotFound) {
+			app.errorResponse(c, errs.NotFound("User to transfer events to not found"))
+			return
+		}
+		if err != nil {
+			app.errorResponse(c, err)
+			return
+		}
+		transferTo = newOwner.Id
+	}
+
+	if err := app.models.Users.Delete(c.Request.Context(), user.Id, transferTo); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
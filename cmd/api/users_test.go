@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestGetCurrentUser(t *testing.T) {
+	ta := newTestApp(t)
+	user, token := ta.createUser(t, "Jane", "jane@example.com", "password123")
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/me", nil, token)
+	assertStatus(t, rec, http.StatusOK)
+	if got := decode[database.User](t, rec); got.Id != user.Id || got.Email != user.Email {
+		t.Fatalf("got %+v, want %+v", got, user)
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/me", nil, "")
+	assertStatus(t, rec, http.StatusUnauthorized)
+	decodeProblem(t, rec)
+
+	// A token for a user that no longer exists is rejected.
+	ghost := &database.User{Id: "user-deleted"}
+	ghostToken, _ := ta.issueToken(ghost)
+	rec = ta.do(t, http.MethodGet, "/api/v1/me", nil, ghostToken)
+	assertStatus(t, rec, http.StatusUnauthorized)
+	decodeProblem(t, rec)
+}
+
+func TestUpdateCurrentUser(t *testing.T) {
+	ta := newTestApp(t)
+	user, token := ta.createUser(t, "Jane", "jane@example.com", "password123")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{"missing token", "", map[string]any{"name": "Janet"}, http.StatusUnauthorized},
+		{"malformed json", token, `{"name"`, http.StatusBadRequest},
+		{"blank name", token, map[string]any{"name": "   "}, http.StatusBadRequest},
+		{"updated", token, map[string]any{"name": "Janet"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPatch, "/api/v1/me", tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	stored, _ := ta.models.Users.GetById(context.Background(), user.Id)
+	if stored.Name != "Janet" {
+		t.Fatalf("stored name = %q, want Janet", stored.Name)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ta := newTestApp(t)
+	user, token := ta.createUser(t, "Jane", "jane@example.com", "password123")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{"missing token", "", map[string]any{"currentPassword": "password123", "newPassword": "newpassword"}, http.StatusUnauthorized},
+		{"malformed json", token, `[]`, http.StatusBadRequest},
+		{"short new password", token, map[string]any{"currentPassword": "password123", "newPassword": "short"}, http.StatusBadRequest},
+		{"wrong current password", token, map[string]any{"currentPassword": "wrong-password", "newPassword": "newpassword"}, http.StatusUnauthorized},
+		{"changed", token, map[string]any{"currentPassword": "password123", "newPassword": "newpassword"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPut, "/api/v1/me/password", tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	stored, _ := ta.models.Users.GetById(context.Background(), user.Id)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("newpassword")) != nil {
+		t.Fatal("password was not changed")
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	ta := newTestApp(t)
+	user, token := ta.createUser(t, "Jane", "jane@example.com", "password123")
+	_, joeToken := ta.createUser(t, "Joe", "joe@example.com", "password123")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{"missing token", "", map[string]any{"email": "new@example.com", "password": "password123"}, http.StatusUnauthorized},
+		{"invalid email", token, map[string]any{"email": "new", "password": "password123"}, http.StatusBadRequest},
+		{"wrong password", token, map[string]any{"email": "new@example.com", "password": "wrong-password"}, http.StatusUnauthorized},
+		{"email in use", token, map[string]any{"email": "joe@example.com", "password": "password123"}, http.StatusConflict},
+		{"requested", token, map[string]any{"email": "new@example.com", "password": "password123"}, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, "/api/v1/me/email", tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusAccepted {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	sent := ta.mail.all()
+	if len(sent) != 1 || sent[0].Recipient != "new@example.com" {
+		t.Fatalf("sent %+v, want a single email to new@example.com", sent)
+	}
+
+	confirmation, err := ta.models.Tokens.NewWithPayload(context.Background(), user.Id, time.Hour, database.ScopeEmailChange, "newer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken, err := ta.models.Tokens.NewWithPayload(context.Background(), user.Id, time.Hour, database.ScopeEmailChange, "joe@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another user can neither redeem the token nor use it up.
+	rec := ta.do(t, http.MethodPost, "/api/v1/me/email/confirm", map[string]any{"token": confirmation.Plaintext}, joeToken)
+	assertStatus(t, rec, http.StatusUnauthorized)
+	decodeProblem(t, rec)
+
+	confirmTests := []struct {
+		name       string
+		body       any
+		wantStatus int
+	}{
+		{"malformed token", map[string]any{"token": "short"}, http.StatusBadRequest},
+		{"unknown token", map[string]any{"token": strings.Repeat("A", 26)}, http.StatusUnauthorized},
+		{"email taken since request", map[string]any{"token": taken.Plaintext}, http.StatusConflict},
+		{"confirmed", map[string]any{"token": confirmation.Plaintext}, http.StatusOK},
+	}
+	for _, tt := range confirmTests {
+		t.Run("confirm "+tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodPost, "/api/v1/me/email/confirm", tt.body, token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	stored, _ := ta.models.Users.GetById(context.Background(), user.Id)
+	if stored.Email != "newer@example.com" {
+		t.Fatalf("stored email = %q, want newer@example.com", stored.Email)
+	}
+}
+
+func TestDeleteCurrentUser(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	heir, _ := ta.createUser(t, "Heir", "heir@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	trashed := ta.createEvent(t, owner.Id, "Old meetup")
+	if err := ta.models.Events.Delete(context.Background(), trashed.Id, trashed.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{"missing token", "", map[string]any{"password": "password123"}, http.StatusUnauthorized},
+		{"malformed json", ownerToken, `{`, http.StatusBadRequest},
+		{"invalid strategy", ownerToken, map[string]any{"password": "password123", "ownedEvents": "keep"}, http.StatusBadRequest},
+		{"wrong password", ownerToken, map[string]any{"password": "wrong-password"}, http.StatusUnauthorized},
+		{"owns events", ownerToken, map[string]any{"password": "password123"}, http.StatusConflict},
+		{"transfer to self", ownerToken, map[string]any{"password": "password123", "ownedEvents": "transfer", "transferTo": owner.Id}, http.StatusBadRequest},
+		{"transfer to unknown user", ownerToken, map[string]any{"password": "password123", "ownedEvents": "transfer", "transferTo": "missing"}, http.StatusNotFound},
+		{"deleted", ownerToken, map[string]any{"password": "password123", "ownedEvents": "transfer", "transferTo": heir.Id}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, http.MethodDelete, "/api/v1/me", tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+			if tt.wantStatus != http.StatusNoContent {
+				decodeProblem(t, rec)
+			}
+		})
+	}
+
+	stored, err := ta.models.Events.Get(context.Background(), event.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.OwnerId != heir.Id {
+		t.Fatalf("event owner = %q, want %q", stored.OwnerId, heir.Id)
+	}
+	last := ta.store.outbox[len(ta.store.outbox)-1]
+	if last.Topic != database.TopicEventUpdated || last.AggregateId != event.Id {
+		t.Fatalf("last outbox message = %s for %s, want the transfer announced", last.Topic, last.AggregateId)
+	}
+	// Events already in the trash are not handed over.
+	stored, err = ta.models.Events.GetDeleted(context.Background(), trashed.Id, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.OwnerId != "" {
+		t.Fatalf("trashed event owner = %q, want none", stored.OwnerId)
+	}
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/me", nil, ownerToken)
+	assertStatus(t, rec, http.StatusUnauthorized)
+}
+
+func TestDeleteCurrentUserCancelsEvents(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	guest, _ := ta.createUser(t, "Guest", "guest@example.com", "password123")
+	event := ta.createEvent(t, owner.Id, "Go meetup")
+	ta.addAttendee(t, event.Id, guest.Id)
+	ta.flushOutbox()
+
+	body := map[string]any{"password": "password123", "ownedEvents": "cancel"}
+	assertStatus(t, ta.do(t, http.MethodDelete, "/api/v1/me", body, ownerToken), http.StatusNoContent)
+
+	// The event is cancelled and kept in the trash, attendance included.
+	if _, err := ta.models.Events.Get(context.Background(), event.Id); err == nil {
+		t.Fatal("cancelled event is still live")
+	}
+	stored, err := ta.models.Events.GetDeleted(context.Background(), event.Id, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != database.EventCancelled || stored.OwnerId != "" {
+		t.Fatalf("event = %+v, want it cancelled without an owner", stored)
+	}
+	if _, err := ta.models.Attendees.GetByEventAndAttendee(context.Background(), event.Id, guest.Id); err != nil {
+		t.Fatalf("attendance was dropped: %v", err)
+	}
+	transitions, _ := ta.models.Events.GetTransitions(context.Background(), event.Id)
+	if len(transitions) != 1 || transitions[0].To != database.EventCancelled {
+		t.Fatalf("transitions = %+v, want the cancellation", transitions)
+	}
+
+	ta.flushOutbox()
+	sent := ta.mail.all()
+	if len(sent) != 1 || sent[0].Recipient != guest.Email || !strings.Contains(sent[0].Body, "deleted their account") {
+		t.Fatalf("sent %+v, want the attendee told about the cancellation", sent)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryLogLimit is the number of deliveries listed per webhook.
+const deliveryLogLimit = 100
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,http_url,max=2000"`
+	EventTypes []string `json:"eventTypes" binding:"required,min=1,dive,oneof=event.created event.updated event.cancelled attendee.added attendee.removed"`
+	// OrganizationId makes the webhook one of the organization's, told
+	// about the events of all its members. Only its admins can set it.
+	OrganizationId string `json:"organizationId"`
+}
+
+// WebhookPayload is the body of every delivery. Id is the id of the domain
+// event; it is kept when a delivery is retried or replayed, so receivers can
+// use it to drop duplicates.
+type WebhookPayload struct {
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// webhookTopics maps the domain events that webhooks are told about onto the
+// event types they subscribe to.
+var webhookTopics = map[string]string{
+	database.TopicEventCreated:    database.WebhookEventCreated,
+	database.TopicEventUpdated:    database.WebhookEventUpdated,
+	database.TopicEventPublished:  database.WebhookEventUpdated,
+	database.TopicEventCompleted:  database.WebhookEventUpdated,
+	database.TopicEventCancelled:  database.WebhookEventCancelled,
+	database.TopicAttendeeAdded:   database.WebhookAttendeeAdded,
+	database.TopicAttendeeRemoved: database.WebhookAttendeeRemoved,
+}
+
+// queueWebhooks is the outbox subscriber that queues a delivery of a domain
+// event to the subscribed webhooks of the event's owner and of the
+// organizations they belong to, and a job to send each. Deliveries already queued for the message are not queued again.
+func (app *application) queueWebhooks(ctx context.Context, msg *database.OutboxMessage) error {
+	var ownerId string
+	var data any
+	if msg.Topic == database.TopicAttendeeAdded || msg.Topic == database.TopicAttendeeRemoved {
+		var change database.AttendeeChanged
+		if err := json.Unmarshal(msg.Payload, &change); err != nil {
+			return err
+		}
+		ownerId, data = change.OwnerId, change.Attendee
+	} else {
+		var change database.EventChanged
+		if err := json.Unmarshal(msg.Payload, &change); err != nil {
+			return err
+		}
+		ownerId, data = change.Event.OwnerId, change.Event
+	}
+
+	eventType := webhookTopics[msg.Topic]
+	payload, err := json.Marshal(WebhookPayload{
+		Id:        msg.Id,
+		Type:      eventType,
+		CreatedAt: msg.CreatedAt.UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	ids, err := app.models.Webhooks.Enqueue(ctx, msg.Id, ownerId, eventType, payload)
+	if err != nil {
+		return err
+	}
+	return app.tasks.QueueWebhookDeliveries(ctx, ids...)
+}
+
+// createWebhook subscribes to changes of the user's or an organization's
+// events
+//
+// @Summary Create a webhook
+// @Description Subscribe a URL to changes of the events you own or, with organizationId, of the events owned by any member of an organization you are an admin of. Deliveries are signed with HMAC-SHA256 in the X-Webhook-Signature header ("t=<unix time>,v1=<hex digest of t.body>") using the returned secret, which is only shown once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Subscription"
+// @Success 201 {object} database.Webhook
+// @Failure 400 {object} Problem
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/webhooks [post]
+// @Security BearerAuth
+func (app *application) createWebhook(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var request CreateWebhookRequest
+	if !app.bindJSON(c, &request) {
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	hook := &database.Webhook{
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+	}
+	if request.OrganizationId != "" {
+		organization, ok := app.managedOrganization(c, user, request.OrganizationId, "Only admins can manage the webhooks of this organization")
+		if !ok {
+			return
+		}
+		hook.OrganizationId = organization.Id
+	} else {
+		hook.UserId = user.Id
+	}
+	if err := app.models.Webhooks.Insert(c.Request.Context(), hook); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, hook)
+}
+
+// getWebhooks lists the user's or an organization's webhooks
+//
+// @Summary Get webhooks
+// @Description List your webhook subscriptions or, with organizationId, those of an organization you are an admin of. Secrets are not included.
+// @Tags webhooks
+// @Produce json
+// @Param organizationId query string false "Organization ID"
+// @Success 200 {array} database.Webhook
+// @Failure 401 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/webhooks [get]
+// @Security BearerAuth
+func (app *application) getWebhooks(c *gin.Context) {
+	user := app.getUserFromContext(c)
+
+	var webhooks []*database.Webhook
+	var err error
+	if id := c.Query("organizationId"); id != "" {
+		organization, ok := app.managedOrganization(c, user, id, "Only admins can manage the webhooks of this organization")
+		if !ok {
+			return
+		}
+		webhooks, err = app.models.Webhooks.GetByOrganization(c.Request.Context(), organization.Id)
+	} else {
+		webhooks, err = app.models.Webhooks.GetByUser(c.Request.Context(), user.Id)
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// getWebhook returns a single webhook
+//
+// @Summary Get a webhook
+// @Description Get one of your webhook subscriptions. The secret is not included.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} database.Webhook
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/webhooks/{id} [get]
+// @Security BearerAuth
+func (app *application) getWebhook(c *gin.Context) {
+	hook, ok := app.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// deleteWebhook removes a webhook
+//
+// @Summary Delete a webhook
+// @Description Remove a webhook subscription together with its delivery log
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204 {object} nil
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/webhooks/{id} [delete]
+// @Security BearerAuth
+func (app *application) deleteWebhook(c *gin.Context) {
+	hook, ok := app.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	if err := app.models.Webhooks.Delete(c.Request.Context(), hook.Id); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getWebhookDeliveries lists the recent deliveries of a webhook
+//
+// @Summary Get webhook deliveries
+// @Description List the 100 most recent deliveries of a webhook, newest first, with their status, attempts and last response
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {array} database.WebhookDelivery
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+// @Security BearerAuth
+func (app *application) getWebhookDeliveries(c *gin.Context) {
+	hook, ok := app.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	deliveries, err := app.models.Webhooks.GetDeliveries(c.Request.Context(), hook.Id, deliveryLogLimit)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// replayWebhookDelivery sends an earlier delivery again
+//
+// @Summary Replay a webhook delivery
+// @Description Queue a new delivery with the same payload as an earlier one, whatever its outcome. The payload id is unchanged so receivers can recognise the repeat.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} database.WebhookDelivery
+// @Failure 401 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /api/v1/webhooks/{id}/deliveries/{deliveryId}/replay [post]
+// @Security BearerAuth
+func (app *application) replayWebhookDelivery(c *gin.Context) {
+	hook, ok := app.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	delivery, err := app.models.Webhooks.GetDelivery(c.Request.Context(), hook.Id, c.Param("deliveryId"))
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	replay, err := app.models.Webhooks.Replay(c.Request.Context(), delivery)
+	if err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	if err := app.tasks.QueueWebhookDeliveries(c.Request.Context(), replay.Id); err != nil {
+		app.errorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, replay)
+}
+
+// ownedWebhook loads the webhook named in the path. Other users' webhooks,
+// and those of organizations the user is not an admin of, are reported as
+// missing.
+func (app *application) ownedWebhook(c *gin.Context) (*database.Webhook, bool) {
+	user := app.getUserFromContext(c)
+	hook, err := app.models.Webhooks.Get(c.Request.Context(), c.Param("id"))
+	if err == nil {
+		err = app.checkManagesWebhook(c, hook, user)
+	}
+	if err != nil {
+		app.errorResponse(c, err)
+		return nil, false
+	}
+	return hook, true
+}
+
+// checkManagesWebhook returns a not-found error unless user owns hook or is
+// an admin of the organization it belongs to.
+func (app *application) checkManagesWebhook(c *gin.Context, hook *database.Webhook, user *database.User) error {
+	if hook.OrganizationId == "" {
+		if hook.UserId != user.Id {
+			return errs.NotFound("Webhook not found")
+		}
+		return nil
+	}
+	organization, err := app.models.Organizations.Get(c.Request.Context(), hook.OrganizationId, user.Id)
+	if err != nil && !errs.Is(err, errs.KindNotFound) {
+		return err
+	}
+	if err != nil || organization.Role != database.OrganizationAdmin {
+		return errs.NotFound("Webhook not found")
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/webhook"
+)
+
+// receiver is a local webhook endpoint that checks signatures and records
+// the payloads it accepts. It answers with the queued status codes first,
+// then 204.
+type receiver struct {
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	payloads []WebhookPayload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	if err := webhook.Verify(r.secret, req.Header.Get(webhook.SignatureHeader), body, time.Minute, time.Now()); err != nil {
+		r.t.Errorf("signature: %v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.statuses) > 0 {
+		w.WriteHeader(r.statuses[0])
+		r.statuses = r.statuses[1:]
+		return
+	}
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Error(err)
+	}
+	if got := req.Header.Get(webhook.EventHeader); got != payload.Type {
+		r.t.Errorf("%s = %q, want %q", webhook.EventHeader, got, payload.Type)
+	}
+	r.payloads = append(r.payloads, payload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *receiver) received() []WebhookPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookPayload(nil), r.payloads...)
+}
+
+// subscribe creates a webhook pointing at a new local receiver.
+func (ta *testApp) subscribe(t *testing.T, token string, eventTypes ...string) (*database.Webhook, *receiver) {
+	t.Helper()
+	return ta.subscribeWith(t, token, map[string]any{"eventTypes": eventTypes})
+}
+
+// subscribeWith creates a webhook from body pointing at a new local
+// receiver.
+func (ta *testApp) subscribeWith(t *testing.T, token string, body map[string]any) (*database.Webhook, *receiver) {
+	t.Helper()
+	r := &receiver{t: t}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	body["url"] = server.URL
+	rec := ta.do(t, http.MethodPost, "/api/v1/webhooks", body, token)
+	assertStatus(t, rec, http.StatusCreated)
+	hook := decode[database.Webhook](t, rec)
+	if hook.Secret == "" {
+		t.Fatal("created webhook has no secret")
+	}
+	r.secret = hook.Secret
+	return &hook, r
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	ta := newTestApp(t)
+	_, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	_, otherToken := ta.createUser(t, "Other", "other@example.com", "password123")
+
+	hook, _ := ta.subscribe(t, ownerToken, database.WebhookEventCreated)
+	path := "/api/v1/webhooks/" + hook.Id
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/webhooks", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	hooks := decode[[]database.Webhook](t, rec)
+	if len(hooks) != 1 || hooks[0].Id != hook.Id || hooks[0].Secret != "" {
+		t.Fatalf("webhooks = %+v, want the subscription without its secret", hooks)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		token      string
+		wantStatus int
+	}{
+		{"create without url", http.MethodPost, "/api/v1/webhooks", map[string]any{"eventTypes": []string{"event.created"}}, ownerToken, http.StatusBadRequest},
+		{"create with bad url", http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "not a url", "eventTypes": []string{"event.created"}}, ownerToken, http.StatusBadRequest},
+		{"create with unknown type", http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "https://example.com/hook", "eventTypes": []string{"event.exploded"}}, ownerToken, http.StatusBadRequest},
+		{"create without types", http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "https://example.com/hook", "eventTypes": []string{}}, ownerToken, http.StatusBadRequest},
+		{"create unauthenticated", http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "https://example.com/hook", "eventTypes": []string{"event.created"}}, "", http.StatusUnauthorized},
+		{"get", http.MethodGet, path, nil, ownerToken, http.StatusOK},
+		{"get not the owner", http.MethodGet, path, nil, otherToken, http.StatusNotFound},
+		{"get missing", http.MethodGet, "/api/v1/webhooks/missing", nil, ownerToken, http.StatusNotFound},
+		{"deliveries not the owner", http.MethodGet, path + "/deliveries", nil, otherToken, http.StatusNotFound},
+		{"replay missing delivery", http.MethodPost, path + "/deliveries/missing/replay", nil, ownerToken, http.StatusNotFound},
+		{"delete not the owner", http.MethodDelete, path, nil, otherToken, http.StatusNotFound},
+		{"delete", http.MethodDelete, path, nil, ownerToken, http.StatusNoContent},
+		{"get deleted", http.MethodGet, path, nil, ownerToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, tt.method, tt.path, tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+		})
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	ta := newTestApp(t)
+	owner, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+	attendee, _ := ta.createUser(t, "Attendee", "attendee@example.com", "password123")
+
+	hook, r := ta.subscribe(t, ownerToken, database.WebhookEventCreated, database.WebhookEventCancelled, database.WebhookAttendeeAdded)
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken)
+	assertStatus(t, rec, http.StatusCreated)
+	event := decode[database.Event](t, rec)
+	path := "/api/v1/events/" + event.Id
+
+	// Not subscribed: no delivery is queued.
+	body := validEventBody()
+	body["name"] = "Renamed"
+	assertStatus(t, ta.do(t, http.MethodPut, path, body, ownerToken), http.StatusOK)
+
+	assertStatus(t, ta.do(t, http.MethodPost, path+"/attendees/"+attendee.Id, nil, ownerToken), http.StatusCreated)
+	assertStatus(t, ta.do(t, http.MethodPost, path+"/cancel", map[string]any{"reason": "Rain"}, ownerToken), http.StatusOK)
+
+	ta.flushOutbox()
+	if n := ta.runJobs(time.Now()); n != 3 {
+		t.Fatalf("delivered %d webhooks, want 3", n)
+	}
+	payloads := r.received()
+	if len(payloads) != 3 {
+		t.Fatalf("received %d payloads, want 3", len(payloads))
+	}
+	types := map[string]map[string]any{}
+	for _, p := range payloads {
+		types[p.Type] = p.Data.(map[string]any)
+	}
+	if types[database.WebhookEventCreated]["id"] != event.Id || types[database.WebhookEventCreated]["ownerId"] != owner.Id {
+		t.Errorf("event.created data = %v, want the event", types[database.WebhookEventCreated])
+	}
+	if types[database.WebhookAttendeeAdded]["userId"] != attendee.Id {
+		t.Errorf("attendee.added data = %v, want the attendee", types[database.WebhookAttendeeAdded])
+	}
+	if types[database.WebhookEventCancelled]["status"] != database.EventCancelled {
+		t.Errorf("event.cancelled data = %v, want a cancelled event", types[database.WebhookEventCancelled])
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/webhooks/"+hook.Id+"/deliveries", nil, ownerToken)
+	assertStatus(t, rec, http.StatusOK)
+	deliveries := decode[[]database.WebhookDelivery](t, rec)
+	if len(deliveries) != 3 {
+		t.Fatalf("deliveries = %+v, want 3", deliveries)
+	}
+	for _, d := range deliveries {
+		if d.Status != database.DeliverySucceeded || d.Attempts != 1 || d.LastStatusCode != http.StatusNoContent || d.DeliveredAt == nil {
+			t.Errorf("delivery = %+v, want succeeded on the first attempt", d)
+		}
+	}
+
+	// A replay resends the same payload, id included.
+	rec = ta.do(t, http.MethodPost, "/api/v1/webhooks/"+hook.Id+"/deliveries/"+deliveries[0].Id+"/replay", nil, ownerToken)
+	assertStatus(t, rec, http.StatusAccepted)
+	if replay := decode[database.WebhookDelivery](t, rec); replay.Status != database.DeliveryPending || replay.Id == deliveries[0].Id {
+		t.Fatalf("replay = %+v, want a new pending delivery", replay)
+	}
+	ta.runJobs(time.Now())
+	var original WebhookPayload
+	if err := json.Unmarshal(deliveries[0].Payload, &original); err != nil {
+		t.Fatal(err)
+	}
+	payloads = r.received()
+	if len(payloads) != 4 || payloads[3].Id != original.Id {
+		t.Fatalf("payloads = %+v, want %s sent again", payloads, original.Id)
+	}
+}
+
+func TestOrganizationWebhooks(t *testing.T) {
+	ta := newTestApp(t)
+	_, adminToken := ta.createUser(t, "Admin", "admin@example.com", "password123")
+	member, memberToken := ta.createUser(t, "Member", "member@example.com", "password123")
+	_, outsiderToken := ta.createUser(t, "Outsider", "outsider@example.com", "password123")
+
+	rec := ta.do(t, http.MethodPost, "/api/v1/organizations", map[string]any{"name": "Go Hanoi"}, adminToken)
+	assertStatus(t, rec, http.StatusCreated)
+	org := decode[database.Organization](t, rec)
+	membersPath := "/api/v1/organizations/" + org.Id + "/members/" + member.Id
+	assertStatus(t, ta.do(t, http.MethodPost, membersPath, map[string]any{"role": database.OrganizationMember}, adminToken), http.StatusCreated)
+
+	hook, r := ta.subscribeWith(t, adminToken, map[string]any{"organizationId": org.Id, "eventTypes": []string{database.WebhookEventCreated}})
+	if hook.OrganizationId != org.Id || hook.UserId != "" {
+		t.Fatalf("webhook = %+v, want it to belong to the organization", hook)
+	}
+	path := "/api/v1/webhooks/" + hook.Id
+	orgBody := map[string]any{"url": "https://example.com/hook", "eventTypes": []string{"event.created"}, "organizationId": org.Id}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		token      string
+		wantStatus int
+	}{
+		{"create as a member", http.MethodPost, "/api/v1/webhooks", orgBody, memberToken, http.StatusForbidden},
+		{"create as an outsider", http.MethodPost, "/api/v1/webhooks", orgBody, outsiderToken, http.StatusNotFound},
+		{"list as a member", http.MethodGet, "/api/v1/webhooks?organizationId=" + org.Id, nil, memberToken, http.StatusForbidden},
+		{"get as an admin", http.MethodGet, path, nil, adminToken, http.StatusOK},
+		{"get as a member", http.MethodGet, path, nil, memberToken, http.StatusNotFound},
+		{"deliveries as an outsider", http.MethodGet, path + "/deliveries", nil, outsiderToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ta.do(t, tt.method, tt.path, tt.body, tt.token)
+			assertStatus(t, rec, tt.wantStatus)
+		})
+	}
+
+	rec = ta.do(t, http.MethodGet, "/api/v1/webhooks?organizationId="+org.Id, nil, adminToken)
+	assertStatus(t, rec, http.StatusOK)
+	if hooks := decode[[]database.Webhook](t, rec); len(hooks) != 1 || hooks[0].Id != hook.Id {
+		t.Fatalf("organization webhooks = %+v, want the subscription", hooks)
+	}
+	rec = ta.do(t, http.MethodGet, "/api/v1/webhooks", nil, adminToken)
+	assertStatus(t, rec, http.StatusOK)
+	if hooks := decode[[]database.Webhook](t, rec); len(hooks) != 0 {
+		t.Fatalf("personal webhooks = %+v, want none", hooks)
+	}
+
+	// Events of any member are delivered, those of outsiders are not.
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), memberToken), http.StatusCreated)
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), outsiderToken), http.StatusCreated)
+	ta.flushOutbox()
+	ta.runJobs(time.Now())
+	payloads := r.received()
+	if len(payloads) != 1 || payloads[0].Data.(map[string]any)["ownerId"] != member.Id {
+		t.Fatalf("payloads = %+v, want the member's event only", payloads)
+	}
+
+	// Once the member leaves, their events are no longer the organization's
+	// business.
+	assertStatus(t, ta.do(t, http.MethodDelete, membersPath, nil, memberToken), http.StatusNoContent)
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), memberToken), http.StatusCreated)
+	ta.flushOutbox()
+	ta.runJobs(time.Now())
+	if n := len(r.received()); n != 1 {
+		t.Fatalf("received %d payloads, want no more after the member left", n)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	ta := newTestApp(t)
+	_, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+
+	hook, r := ta.subscribe(t, ownerToken, database.WebhookEventCreated)
+	r.statuses = []int{http.StatusInternalServerError, http.StatusBadGateway}
+	deliveries := func() []database.WebhookDelivery {
+		rec := ta.do(t, http.MethodGet, "/api/v1/webhooks/"+hook.Id+"/deliveries", nil, ownerToken)
+		assertStatus(t, rec, http.StatusOK)
+		return decode[[]database.WebhookDelivery](t, rec)
+	}
+
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken), http.StatusCreated)
+	ta.flushOutbox()
+
+	now := time.Now()
+	ta.runJobs(now)
+	d := deliveries()[0]
+	if d.Status != database.DeliveryPending || d.Attempts != 1 || d.LastStatusCode != http.StatusInternalServerError || d.LastError == "" {
+		t.Fatalf("delivery = %+v, want a failed first attempt", d)
+	}
+	if want := now.Add(webhook.Backoff(1)); d.NextAttemptAt == nil || !d.NextAttemptAt.Equal(want) {
+		t.Fatalf("next attempt = %v, want %v", d.NextAttemptAt, want)
+	}
+
+	// Not due yet.
+	if n := ta.runJobs(now.Add(time.Second)); n != 0 {
+		t.Fatalf("claimed %d deliveries before the backoff elapsed", n)
+	}
+
+	now = now.Add(time.Hour)
+	ta.runJobs(now)
+	if d := deliveries()[0]; d.Attempts != 2 || d.LastStatusCode != http.StatusBadGateway {
+		t.Fatalf("delivery = %+v, want a failed second attempt", d)
+	}
+
+	now = now.Add(time.Hour)
+	ta.runJobs(now)
+	if d := deliveries()[0]; d.Status != database.DeliverySucceeded || d.Attempts != 3 || d.LastError != "" || d.NextAttemptAt != nil {
+		t.Fatalf("delivery = %+v, want success on the third attempt", d)
+	}
+	if len(r.received()) != 1 {
+		t.Fatalf("received %d payloads, want 1", len(r.received()))
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	ta := newTestApp(t)
+	_, ownerToken := ta.createUser(t, "Owner", "owner@example.com", "password123")
+
+	hook, r := ta.subscribe(t, ownerToken, database.WebhookEventCreated)
+	r.statuses = []int{500, 500, 500, 500}
+
+	assertStatus(t, ta.do(t, http.MethodPost, "/api/v1/events", validEventBody(), ownerToken), http.StatusCreated)
+	ta.flushOutbox()
+
+	now := time.Now()
+	for range ta.config.Webhooks.MaxAttempts + 1 {
+		ta.runJobs(now)
+		now = now.Add(24 * time.Hour)
+	}
+
+	rec := ta.do(t, http.MethodGet, "/api/v1/webhooks/"+hook.Id+"/deliveries", nil, ownerToken)
+	d := decode[[]database.WebhookDelivery](t, rec)[0]
+	if d.Status != database.DeliveryFailed || d.Attempts != ta.config.Webhooks.MaxAttempts || d.NextAttemptAt != nil {
+		t.Fatalf("delivery = %+v, want failed after %d attempts", d, ta.config.Webhooks.MaxAttempts)
+	}
+}
@@ -0,0 +1,106 @@
+// Command worker runs the background jobs of the API (event reminders,
+// exports, purges and webhook deliveries) apart from cmd/api. Any number of
+// workers can run against the same database; set RUN_JOBS=false on the API
+// when they do.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/config"
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/logger"
+	"github.com/davidcm146/event-rest-api/internal/mailer"
+	"github.com/davidcm146/event-rest-api/internal/tasks"
+	"github.com/davidcm146/event-rest-api/internal/tracing"
+	"github.com/davidcm146/event-rest-api/internal/webhook"
+	_ "github.com/joho/godotenv/autoload"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	var cfg config.Worker
+	if err := config.Load(&cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	// The API serves the archives, so both must use the same directory.
+	if cfg.Export.Dir == "" {
+		cfg.Export.Dir = filepath.Join(os.TempDir(), "event-api-exports")
+	}
+
+	log, err := logger.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure logger: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(log)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		log.Error("failed to configure tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := sql.Open("postgres", cfg.Database.URL)
+	if err != nil {
+		log.Error("failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	t := tasks.New(
+		tasks.Config{Export: cfg.Export, Events: cfg.Events, Webhooks: cfg.Webhooks, Jobs: cfg.Jobs},
+		database.NewModels(db),
+		mailer.New(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Sender),
+		webhook.NewClient(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivate),
+		log,
+	)
+
+	if err := run(t, cfg.ShutdownTimeout, log); err != nil {
+		log.Error("worker stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run works through the job queue until SIGINT or SIGTERM is received, then
+// gives the running jobs the shutdown timeout to finish. Jobs that do not
+// finish in time are picked up again by another worker once their claim
+// runs out.
+func run(t *tasks.Tasks, shutdownTimeout time.Duration, log *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := t.Start(ctx); err != nil {
+		return fmt.Errorf("failed to schedule recurring jobs: %w", err)
+	}
+
+	worker := t.Worker()
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	log.Info("starting worker")
+	<-ctx.Done()
+	stop()
+	log.Info("shutting down worker", "timeout", shutdownTimeout)
+
+	select {
+	case <-done:
+		log.Info("worker stopped")
+		return nil
+	case <-time.After(shutdownTimeout):
+		return fmt.Errorf("running jobs did not finish within %s", shutdownTimeout)
+	}
+}
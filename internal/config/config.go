@@ -0,0 +1,139 @@
+package config
+
+import "time"
+
+// Database is shared by every binary that talks to Postgres.
+type Database struct {
+	URL string `env:"DATABASE_URL" required:"true"`
+}
+
+type Log struct {
+	Level  string `env:"LOG_LEVEL" default:"info" oneof:"debug info warn error"`
+	Format string `env:"LOG_FORMAT" default:"json" oneof:"json text"`
+}
+
+type Tracing struct {
+	ServiceName string `env:"OTEL_SERVICE_NAME" default:"event-rest-api"`
+	Exporter    string `env:"OTEL_TRACES_EXPORTER" default:"none" oneof:"none otlp stdout"`
+}
+
+type SMTP struct {
+	Host     string `env:"SMTP_HOST"`
+	Port     int    `env:"SMTP_PORT" default:"587" min:"1"`
+	Username string `env:"SMTP_USERNAME"`
+	Password string `env:"SMTP_PASSWORD"`
+	Sender   string `env:"SMTP_SENDER" default:"Event API <no-reply@example.com>"`
+}
+
+type Export struct {
+	Dir       string        `env:"EXPORT_DIR"`
+	TTL       time.Duration `env:"EXPORT_TTL" default:"24h" min:"1m"`
+	SyncLimit int           `env:"EXPORT_SYNC_LIMIT" default:"500" min:"0"`
+}
+
+type Events struct {
+	// RetentionDays is how long deleted events can be restored before
+	// they are purged.
+	RetentionDays int           `env:"EVENT_RETENTION_DAYS" default:"30" min:"0"`
+	PurgeInterval time.Duration `env:"EVENT_PURGE_INTERVAL" default:"1h" min:"1m"`
+	// ReminderLead is how long before an event its attendees are
+	// reminded of it.
+	ReminderLead time.Duration `env:"EVENT_REMINDER_LEAD" default:"24h" min:"0s"`
+}
+
+type Webhooks struct {
+	Timeout     time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s" min:"1s"`
+	MaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" default:"8" min:"1"`
+	// AllowPrivate lets webhooks target loopback and private addresses,
+	// which is only meant for local development.
+	AllowPrivate bool `env:"WEBHOOK_ALLOW_PRIVATE" default:"false"`
+}
+
+type Jobs struct {
+	PollInterval time.Duration `env:"JOB_POLL_INTERVAL" default:"1s" min:"10ms"`
+	// Concurrency is how many jobs of each kind a process runs at once.
+	Concurrency int `env:"JOB_CONCURRENCY" default:"4" min:"1"`
+	// Retention is how long finished jobs are kept before they are
+	// deleted. Dead jobs are kept until they are deleted by hand.
+	Retention time.Duration `env:"JOB_RETENTION" default:"168h" min:"1m"`
+}
+
+// API is the configuration of cmd/api.
+type API struct {
+	Database Database
+
+	Port           int      `env:"PORT" default:"8080" min:"1"`
+	BaseURL        string   `env:"BASE_URL" default:"http://localhost:8080"`
+	JWTSecret      string   `env:"JWT_SECRET" required:"true"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+	SwaggerEnabled bool     `env:"SWAGGER_ENABLED" default:"true"`
+	AutoMigrate    bool     `env:"AUTO_MIGRATE" default:"false"`
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s" min:"1s"`
+
+	Log     Log
+	Tracing Tracing
+
+	Metrics struct {
+		Addr  string `env:"METRICS_ADDR"`
+		Token string `env:"METRICS_TOKEN"`
+	}
+
+	SMTP SMTP
+
+	MagicLink struct {
+		URL        string        `env:"MAGIC_LINK_URL" default:"http://localhost:8080/magic-link"`
+		TTL        time.Duration `env:"MAGIC_LINK_TTL" default:"15m" min:"1m"`
+		IPLimit    int           `env:"MAGIC_LINK_IP_LIMIT" default:"10" min:"1"`
+		EmailLimit int           `env:"MAGIC_LINK_EMAIL_LIMIT" default:"3" min:"1"`
+	}
+
+	Export Export
+	Events Events
+
+	Idempotency struct {
+		TTL time.Duration `env:"IDEMPOTENCY_TTL" default:"24h" min:"1m"`
+		// LockTimeout is how long a request holds its key before a retry
+		// may take it over. It should outlast the slowest request.
+		LockTimeout time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" default:"1m" min:"1s"`
+	}
+
+	Webhooks Webhooks
+
+	Outbox struct {
+		PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" default:"1s" min:"10ms"`
+		// Retention is how long relayed messages are kept before they are
+		// deleted.
+		Retention time.Duration `env:"OUTBOX_RETENTION" default:"168h" min:"1m"`
+	}
+
+	Jobs Jobs
+	// RunJobs runs the job workers inside the API process. Turn it off when
+	// cmd/worker is deployed.
+	RunJobs bool `env:"RUN_JOBS" default:"true"`
+}
+
+// Worker is the configuration of cmd/worker.
+type Worker struct {
+	Database Database
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s" min:"1s"`
+
+	Log     Log
+	Tracing Tracing
+	SMTP    SMTP
+	Export  Export
+	Events  Events
+
+	Webhooks Webhooks
+	Jobs     Jobs
+}
+
+// Migrate is the configuration of cmd/migrate.
+type Migrate struct {
+	Database Database
+
+	// MigrationsDir is where `migrate create` writes new files. Applying
+	// migrations always uses the copies embedded in the binary.
+	MigrationsDir string `env:"MIGRATIONS_DIR" default:"internal/database/migrations"`
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load fills dst, a pointer to a struct, from (in increasing order of
+// precedence) field defaults, the optional file named by CONFIG_FILE and
+// environment variables. Fields are described with struct tags:
+//
+//	env:"NAME"        variable (and flattened file key) to read
+//	default:"value"   used when nothing else sets the field
+//	required:"true"   loading fails when no value is found
+//	oneof:"a b c"     restricts the value to the listed options
+//	min:"value"       lower bound of a number or duration
+//
+// Every variable can also be read from a file by setting NAME_FILE to its
+// path, which is how container secrets are usually mounted. Supported field
+// types are string, int, bool, float64, time.Duration, []string (comma
+// separated) and nested structs. All problems are reported together.
+func Load(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: destination must be a pointer to a struct")
+	}
+
+	fileValues := map[string]string{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		fileValues, err = readFile(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	load(v.Elem(), fileValues, &errs)
+	return errors.Join(errs...)
+}
+
+func load(v reflect.Value, fileValues map[string]string, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+				load(fv, fileValues, errs)
+			}
+			continue
+		}
+
+		value, found, err := lookup(name, fileValues)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		if !found {
+			value, found = field.Tag.Lookup("default")
+		}
+		if !found || value == "" {
+			if field.Tag.Get("required") == "true" {
+				*errs = append(*errs, fmt.Errorf("%s is required", name))
+			}
+			continue
+		}
+
+		if options := field.Tag.Get("oneof"); options != "" && !contains(strings.Fields(options), value) {
+			*errs = append(*errs, fmt.Errorf("%s must be one of [%s], got %q", name, options, value))
+			continue
+		}
+
+		if err := set(fv, value); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		if min := field.Tag.Get("min"); min != "" {
+			if err := checkMin(fv, min); err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+}
+
+// checkMin fails if the number or duration in fv is below min, which is
+// written like a value of the field.
+func checkMin(fv reflect.Value, min string) error {
+	limit := reflect.New(fv.Type()).Elem()
+	if err := set(limit, min); err != nil {
+		return fmt.Errorf("invalid min tag: %w", err)
+	}
+
+	var below bool
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int64:
+		below = fv.Int() < limit.Int()
+	case reflect.Float64:
+		below = fv.Float() < limit.Float()
+	default:
+		return fmt.Errorf("min is not supported for %s", fv.Type())
+	}
+	if below {
+		return fmt.Errorf("must be at least %s, got %v", min, fv.Interface())
+	}
+	return nil
+}
+
+// lookup resolves a single key from NAME_FILE, NAME or the config file.
+// Empty values count as unset so blank lines in a .env file fall through to
+// the defaults.
+func lookup(name string, fileValues map[string]string) (string, bool, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	if value := os.Getenv(name); value != "" {
+		return value, true, nil
+	}
+	value := fileValues[name]
+	return value, value != "", nil
+}
+
+func set(fv reflect.Value, value string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		fv.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		fv.SetBool(b)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", fv.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// readFile parses a YAML or TOML config file and flattens it into the same
+// keys used for environment variables, so
+//
+//	database:
+//	  url: postgres://...
+//
+// sets DATABASE_URL.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file: unsupported format %q, use .yaml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", raw, values)
+	return values, nil
+}
+
+func flatten(prefix string, raw map[string]any, values map[string]string) {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch value := raw[k].(type) {
+		case map[string]any:
+			flatten(key, value, values)
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequired sets the variables without defaults that the API needs and
+// clears CONFIG_FILE.
+func setRequired(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://localhost/events")
+	t.Setenv("JWT_SECRET", "secret")
+}
+
+func TestLoadDefaults(t *testing.T) {
+	setRequired(t)
+	for _, name := range []string{"PORT", "JOB_POLL_INTERVAL", "JOB_CONCURRENCY", "RUN_JOBS"} {
+		t.Setenv(name, "")
+	}
+
+	var cfg API
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8080 || cfg.Jobs.PollInterval != time.Second || cfg.Jobs.Concurrency != 4 || !cfg.RunJobs {
+		t.Fatalf("cfg = %+v, want the defaults", cfg)
+	}
+	if cfg.Database.URL != "postgres://localhost/events" {
+		t.Fatalf("DATABASE_URL = %q", cfg.Database.URL)
+	}
+}
+
+func TestLoadSources(t *testing.T) {
+	setRequired(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	config := "port: 9000\nlog:\n  level: debug\ntrusted-proxies:\n  - 10.0.0.1\n  - 10.0.0.2\n"
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(dir, "jwt_secret")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("JWT_SECRET_FILE", secret)
+	// The environment takes precedence over the file.
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("JOB_POLL_INTERVAL", "250ms")
+
+	var cfg API
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9000 || cfg.Log.Level != "warn" || cfg.JWTSecret != "from-file" || cfg.Jobs.PollInterval != 250*time.Millisecond {
+		t.Fatalf("cfg = %+v, want values from the file and the environment", cfg)
+	}
+	if !slices.Equal(cfg.TrustedProxies, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Fatalf("TrustedProxies = %v", cfg.TrustedProxies)
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        string
+	}{
+		{"LOG_LEVEL", "verbose", "LOG_LEVEL must be one of"},
+		{"PORT", "http", `PORT: invalid integer "http"`},
+		{"EXPORT_TTL", "1 day", `EXPORT_TTL: invalid duration "1 day"`},
+		{"OUTBOX_POLL_INTERVAL", "0s", "OUTBOX_POLL_INTERVAL: must be at least 10ms, got 0s"},
+		{"JOB_POLL_INTERVAL", "0s", "JOB_POLL_INTERVAL: must be at least 10ms, got 0s"},
+		{"EVENT_PURGE_INTERVAL", "0s", "EVENT_PURGE_INTERVAL: must be at least 1m, got 0s"},
+		{"JOB_CONCURRENCY", "0", "JOB_CONCURRENCY: must be at least 1, got 0"},
+		{"WEBHOOK_MAX_ATTEMPTS", "-1", "WEBHOOK_MAX_ATTEMPTS: must be at least 1, got -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			t.Setenv(tt.name, tt.value)
+
+			var cfg API
+			err := Load(&cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JOB_CONCURRENCY", "0")
+
+	var cfg API
+	err := Load(&cfg)
+	if err == nil {
+		t.Fatal("Load() succeeded without the required variables")
+	}
+	for _, want := range []string{"DATABASE_URL is required", "JWT_SECRET is required", "JOB_CONCURRENCY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsNonStruct(t *testing.T) {
+	var n int
+	if err := Load(&n); err == nil {
+		t.Fatal("Load() accepted a pointer to an int")
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
 )
 
+// AttendeeModel stores RSVPs. Every change is recorded in the audit log and
+// announced in the outbox as part of the same transaction.
 type AttendeeModel struct {
 	DB *sql.DB
 }
@@ -16,19 +20,40 @@ type Attendee struct {
 	EventId string `json:"eventId"`
 }
 
-func (m *AttendeeModel) Insert(attendee *Attendee) (*Attendee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *AttendeeModel) Insert(ctx context.Context, attendee *Attendee) (*Attendee, error) {
+	ctx, span := startSpan(ctx, "AttendeeModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO attendees (user_id, event_id) VALUES ($1, $2) RETURNING id`
-	err := m.DB.QueryRowContext(ctx, query, attendee.UserId, attendee.EventId).Scan(&attendee.Id)
+	err = tx.QueryRowContext(ctx, query, attendee.UserId, attendee.EventId).Scan(&attendee.Id)
 	if err != nil {
-		return nil, err
+		return nil, spanError(span, mapError(err))
+	}
+	if err := recordAudit(ctx, tx, ActionCreate, EntityAttendee, attendee.Id, nil, attendee); err != nil {
+		return nil, spanError(span, err)
+	}
+	if err := publishAttendeeChange(ctx, tx, TopicAttendeeAdded, attendee); err != nil {
+		return nil, spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, spanError(span, err)
 	}
+	setRows(span, 1)
 	return attendee, nil
 }
 
-func (m *AttendeeModel) GetByEventAndAttendee(eventId, userId string) (*Attendee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *AttendeeModel) GetByEventAndAttendee(ctx context.Context, eventId, userId string) (*Attendee, error) {
+	ctx, span := startSpan(ctx, "AttendeeModel.GetByEventAndAttendee")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	query := `SELECT id, user_id, event_id FROM attendees WHERE event_id = $1 AND user_id = $2`
@@ -36,67 +61,137 @@ func (m *AttendeeModel) GetByEventAndAttendee(eventId, userId string) (*Attendee
 	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.Id, &attendee.UserId, &attendee.EventId)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil // No attendee found
+			return nil, errs.NotFound("Attendee not found")
 		}
-		return nil, err // Some other error occurred
+		return nil, spanError(span, err) // Some other error occurred
 	}
+	setRows(span, 1)
 	return &attendee, nil // Attendee found
 }
 
-func (m *AttendeeModel) GetAttendeesByEventId(eventId string) ([]*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *AttendeeModel) GetAttendeesByEventId(ctx context.Context, eventId string) ([]*User, error) {
+	ctx, span := startSpan(ctx, "AttendeeModel.GetAttendeesByEventId")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	query := `SELECT u.id, u.name, u.email FROM attendees a JOIN users u ON a.user_id = u.id WHERE event_id = $1`
+	query := `SELECT u.id, u.name, u.email FROM attendees a
+		JOIN users u ON a.user_id = u.id
+		JOIN events e ON a.event_id = e.id
+		WHERE a.event_id = $1 AND e.deleted_at IS NULL`
 	var attendees []*User
 	rows, err := m.DB.QueryContext(ctx, query, eventId)
 
 	if err != nil {
-		return nil, err
+		return nil, spanError(span, err)
 	}
 
 	defer rows.Close()
 	for rows.Next() {
 		row := &User{}
 		if err := rows.Scan(&row.Id, &row.Name, &row.Email); err != nil {
-			return nil, err
+			return nil, spanError(span, err)
 		}
 		attendees = append(attendees, row)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(attendees)))
 	return attendees, nil
 }
 
-func (m *AttendeeModel) GetEventsByAttendeeId(userId string) ([]*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *AttendeeModel) GetEventsByAttendeeId(ctx context.Context, userId string) ([]*Event, error) {
+	ctx, span := startSpan(ctx, "AttendeeModel.GetEventsByAttendeeId")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	query := `SELECT e.id, e.name, e.owner_id, e.description, e.date, e.location FROM attendees a JOIN events e ON a.event_id = e.id WHERE a.user_id = $1`
+	query := `SELECT e.id, e.name, COALESCE(e.owner_id::text, ''), e.description, e.date, e.location, e.version, e.status, e.visibility, e.attendees_public FROM attendees a JOIN events e ON a.event_id = e.id WHERE a.user_id = $1 AND e.deleted_at IS NULL AND e.status <> 'draft'`
 	var events []*Event
 	rows, err := m.DB.QueryContext(ctx, query, userId)
 
 	if err != nil {
-		return nil, err
+		return nil, spanError(span, err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		row := &Event{}
+		if err := rows.Scan(&row.Id, &row.Name, &row.OwnerId, &row.Description, &row.Date, &row.Location, &row.Version, &row.Status, &row.Visibility, &row.AttendeesPublic); err != nil {
+			return nil, spanError(span, err)
+		}
+		events = append(events, row)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(events)))
+	return events, nil
+}
+
+// GetAttendance lists every event the user attends, drafts and events in the
+// trash included, for exports of the user's data.
+func (m *AttendeeModel) GetAttendance(ctx context.Context, userId string) ([]*Event, error) {
+	ctx, span := startSpan(ctx, "AttendeeModel.GetAttendance")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
+	query := `SELECT e.id, e.name, COALESCE(e.owner_id::text, ''), e.description, e.date, e.location, e.version, e.status, e.visibility, e.attendees_public, e.deleted_at
+		FROM attendees a JOIN events e ON a.event_id = e.id WHERE a.user_id = $1 ORDER BY a.created_at, a.id`
+	rows, err := m.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
 	defer rows.Close()
+
+	events := []*Event{}
 	for rows.Next() {
 		row := &Event{}
-		if err := rows.Scan(&row.Id, &row.Name, &row.OwnerId, &row.Description, &row.Date, &row.Location); err != nil {
-			return nil, err
+		if err := rows.Scan(&row.Id, &row.Name, &row.OwnerId, &row.Description, &row.Date, &row.Location, &row.Version, &row.Status, &row.Visibility, &row.AttendeesPublic, &row.DeletedAt); err != nil {
+			return nil, spanError(span, err)
 		}
 		events = append(events, row)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(events)))
 	return events, nil
 }
 
-func (m *AttendeeModel) Delete(userId, eventId string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *AttendeeModel) Delete(ctx context.Context, userId, eventId string) error {
+	ctx, span := startSpan(ctx, "AttendeeModel.Delete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM attendees WHERE user_id = $1 AND event_id = $2`
-	_, err := m.DB.ExecContext(ctx, query, userId, eventId)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM attendees WHERE user_id = $1 AND event_id = $2 RETURNING id`
+	attendee := &Attendee{UserId: userId, EventId: eventId}
+	err = tx.QueryRowContext(ctx, query, userId, eventId).Scan(&attendee.Id)
+	if err == sql.ErrNoRows {
+		setRows(span, 0)
+		return nil
+	}
 	if err != nil {
-		return err
+		return spanError(span, err)
+	}
+	if err := recordAudit(ctx, tx, ActionDelete, EntityAttendee, attendee.Id, attendee, nil); err != nil {
+		return spanError(span, err)
+	}
+	if err := publishAttendeeChange(ctx, tx, TopicAttendeeRemoved, attendee); err != nil {
+		return spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
 	}
+	setRows(span, 1)
 	return nil
 }
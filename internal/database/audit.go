@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Audited entity types.
+const (
+	EntityEvent    = "event"
+	EntityAttendee = "attendee"
+	EntityUser     = "user"
+)
+
+// Audited actions.
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionRestore        = "restore"
+	ActionPurge          = "purge"
+	ActionTransition     = "transition"
+	ActionPasswordChange = "password_change"
+)
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// AuditEntry records one change to a user, event or attendee.
+type AuditEntry struct {
+	Id         int64                  `json:"id"`
+	ActorId    string                 `json:"actorId,omitempty"`
+	Action     string                 `json:"action"`
+	EntityType string                 `json:"entityType"`
+	EntityId   string                 `json:"entityId"`
+	Changes    map[string]AuditChange `json:"changes"`
+	RequestId  string                 `json:"requestId,omitempty"`
+	IP         string                 `json:"ip,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// AuditChange is the value of a field before and after a change. Before is
+// null for fields that were created and After for fields that were removed.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	EntityType string
+	EntityId   string
+	ActorId    string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+// AuditInfo describes who makes the changes done with a context.
+type AuditInfo struct {
+	ActorId   string
+	RequestId string
+	IP        string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns a copy of ctx whose changes are attributed to info.
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// AuditInfoFrom returns the attribution stored in ctx. Changes made without
+// one, such as those of background jobs, have no actor.
+func AuditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	return info
+}
+
+// NewAuditEntry describes the change of an entity from before to after,
+// attributed to the actor in ctx. Either side may be nil. Fields are
+// compared by their JSON encoding and only those that differ are kept.
+func NewAuditEntry(ctx context.Context, action, entityType, entityId string, before, after any) (*AuditEntry, error) {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	info := AuditInfoFrom(ctx)
+	return &AuditEntry{
+		ActorId:    info.ActorId,
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		Changes:    changes,
+		RequestId:  info.RequestId,
+		IP:         info.IP,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+func auditDiff(before, after any) (map[string]AuditChange, error) {
+	old, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]AuditChange{}
+	for name, value := range old {
+		if !reflect.DeepEqual(value, cur[name]) {
+			changes[name] = AuditChange{Before: value, After: cur[name]}
+		}
+	}
+	for name, value := range cur {
+		if _, ok := old[name]; !ok {
+			changes[name] = AuditChange{After: value}
+		}
+	}
+	return changes, nil
+}
+
+func auditFields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// recordAudit appends an entry for the change to the audit log as part of
+// tx, so that it is only kept if the change itself is committed.
+func recordAudit(ctx context.Context, tx *sql.Tx, action, entityType, entityId string, before, after any) error {
+	entry, err := NewAuditEntry(ctx, action, entityType, entityId, before, after)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_log (actor_id, action, entity_type, entity_id, changes, request_id, ip)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, '')::inet)`
+	// lib/pq sends []byte as bytea, which JSONB does not accept.
+	_, err = tx.ExecContext(ctx, query, entry.ActorId, entry.Action, entry.EntityType, entry.EntityId, string(changes), entry.RequestId, entry.IP)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query returns the entries matching filter, newest first.
+func (m *AuditModel) Query(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	ctx, span := startSpan(ctx, "AuditModel.Query")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.EntityType != "" {
+		where("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityId != "" {
+		where("entity_id = $%d", filter.EntityId)
+	}
+	if filter.ActorId != "" {
+		where("actor_id::text = $%d", filter.ActorId)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < $%d", filter.To)
+	}
+
+	query := `SELECT id, COALESCE(actor_id::text, ''), action, entity_type, entity_id, changes,
+		COALESCE(request_id, ''), COALESCE(host(ip), ''), created_at FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry := &AuditEntry{}
+		var changes []byte
+		if err := rows.Scan(&entry.Id, &entry.ActorId, &entry.Action, &entry.EntityType, &entry.EntityId, &changes,
+			&entry.RequestId, &entry.IP, &entry.CreatedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, spanError(span, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(entries)))
+	return entries, nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/lib/pq"
+)
+
+// ErrDuplicate is returned when a write violates a unique constraint, e.g. a
+// second account with the same email or a repeated RSVP.
+var ErrDuplicate = errors.New("duplicate record")
+
+// ErrEditConflict is returned when a row changed between being read and
+// being written, i.e. its version no longer matches.
+var ErrEditConflict = errors.New("edit conflict")
+
+// ErrLockLost is returned when an idempotency reservation was taken over by
+// a retry after its lock ran out, so its request may no longer record or
+// release it.
+var ErrLockLost = errors.New("idempotency lock lost")
+
+// uniqueViolation is the Postgres SQLSTATE for unique_violation.
+const uniqueViolation = "23505"
+
+// mapError translates driver errors the handlers need to tell apart into
+// typed errors. Unique violations become a conflict wrapping ErrDuplicate;
+// other errors are returned unchanged.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return errs.E(errs.KindConflict, "Record already exists", fmt.Errorf("%w: %s", ErrDuplicate, pqErr.Constraint))
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+)
+
+// EventRevision is a snapshot of the editable fields of an event, taken for
+// every version of the event, whether it was created, updated, transitioned,
+// moved in or out of the trash or handed to another owner. It is numbered by
+// the event version it produced.
+type EventRevision struct {
+	EventId         string    `json:"eventId"`
+	Version         int       `json:"version"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Date            string    `json:"date"`
+	Location        string    `json:"location"`
+	Visibility      string    `json:"visibility" enums:"public,unlisted,private"`
+	AttendeesPublic bool      `json:"attendeesPublic"`
+	EditorId        string    `json:"editorId,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// revisionContent is the part of a revision that can differ between two
+// revisions of the same event.
+type revisionContent struct {
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	Date            string `json:"date"`
+	Location        string `json:"location"`
+	Visibility      string `json:"visibility"`
+	AttendeesPublic bool   `json:"attendeesPublic"`
+}
+
+func (r *EventRevision) content() revisionContent {
+	return revisionContent{r.Name, r.Description, r.Date, r.Location, r.Visibility, r.AttendeesPublic}
+}
+
+// DiffRevisions lists the fields that differ between two revisions.
+func DiffRevisions(from, to *EventRevision) (map[string]AuditChange, error) {
+	return auditDiff(from.content(), to.content())
+}
+
+// Apply copies the revision's content onto event.
+func (r *EventRevision) Apply(event *Event) {
+	event.Name = r.Name
+	event.Description = r.Description
+	event.Date = r.Date
+	event.Location = r.Location
+	event.Visibility = r.Visibility
+	event.AttendeesPublic = r.AttendeesPublic
+}
+
+// saveRevision stores the current content of the event as a revision, as
+// part of tx.
+func saveRevision(ctx context.Context, tx *sql.Tx, eventId string) error {
+	query := `INSERT INTO event_revisions (event_id, version, name, description, date, location, visibility, attendees_public, editor_id)
+		SELECT id, version, name, description, date, location, visibility, attendees_public, NULLIF($2, '')::uuid
+		FROM events WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, eventId, AuditInfoFrom(ctx).ActorId); err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+	return nil
+}
+
+// GetRevisions returns the revisions of an event, newest first.
+func (m *EventModel) GetRevisions(ctx context.Context, eventId string) ([]*EventRevision, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetRevisions")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT event_id, version, name, description, date, location, visibility, attendees_public,
+		COALESCE(editor_id::text, ''), created_at
+		FROM event_revisions WHERE event_id = $1 ORDER BY version DESC`
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	revisions := []*EventRevision{}
+	for rows.Next() {
+		r := &EventRevision{}
+		if err := rows.Scan(&r.EventId, &r.Version, &r.Name, &r.Description, &r.Date, &r.Location, &r.Visibility,
+			&r.AttendeesPublic, &r.EditorId, &r.CreatedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(revisions)))
+	return revisions, nil
+}
+
+// GetRevision returns a single revision of an event.
+func (m *EventModel) GetRevision(ctx context.Context, eventId string, version int) (*EventRevision, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetRevision")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT event_id, version, name, description, date, location, visibility, attendees_public,
+		COALESCE(editor_id::text, ''), created_at
+		FROM event_revisions WHERE event_id = $1 AND version = $2`
+	r := &EventRevision{}
+	err := m.DB.QueryRowContext(ctx, query, eventId, version).Scan(&r.EventId, &r.Version, &r.Name, &r.Description,
+		&r.Date, &r.Location, &r.Visibility, &r.AttendeesPublic, &r.EditorId, &r.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Revision not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return r, nil
+}
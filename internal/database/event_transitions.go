@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Event statuses. New events are drafts, visible only to their owner, until
+// they are published.
+const (
+	EventDraft     = "draft"
+	EventPublished = "published"
+	EventCancelled = "cancelled"
+	EventCompleted = "completed"
+)
+
+// eventTransitions lists the statuses each status may move to. Cancelled and
+// completed are final.
+var eventTransitions = map[string][]string{
+	EventDraft:     {EventPublished, EventCancelled},
+	EventPublished: {EventCancelled, EventCompleted},
+}
+
+// CanTransition reports whether an event may move from one status to
+// another.
+func CanTransition(from, to string) bool {
+	return slices.Contains(eventTransitions[from], to)
+}
+
+// IsFinal reports whether status is one an event can no longer leave.
+// Events in a final status cannot be edited either.
+func IsFinal(status string) bool {
+	return status == EventCancelled || status == EventCompleted
+}
+
+// EventTransition records a status change of an event.
+type EventTransition struct {
+	Id        string    `json:"id"`
+	EventId   string    `json:"eventId"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ActorId   string    `json:"actorId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Transition moves the event to status to and records the change, in a
+// single transaction. Like Update, it fails with a conflict wrapping
+// ErrEditConflict if the event changed since it was read. The caller is
+// expected to have checked CanTransition.
+func (m *EventModel) Transition(ctx context.Context, event *Event, to, reason, actorId string) (*EventTransition, error) {
+	ctx, span := startSpan(ctx, "EventModel.Transition")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var transition *EventTransition
+	var version int
+	_, err := m.auditEvent(ctx, ActionTransition, event.Id, transitionTopics[to], reason, func(tx *sql.Tx) (err error) {
+		transition, version, err = transitionEvent(ctx, tx, event, to, reason, actorId)
+		return err
+	})
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	event.Status = to
+	event.Version = version
+	setRows(span, 1)
+	return transition, nil
+}
+
+// transitionEvent moves the event to status to and records the change as
+// part of tx, and returns the event's new version. It returns sql.ErrNoRows
+// if the stored event no longer matches event's version and status.
+func transitionEvent(ctx context.Context, tx *sql.Tx, event *Event, to, reason, actorId string) (*EventTransition, int, error) {
+	var version int
+	query := `UPDATE events SET status = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND status = $4 AND deleted_at IS NULL RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, to, event.Id, event.Version, event.Status).Scan(&version); err != nil {
+		return nil, 0, err
+	}
+
+	transition := &EventTransition{EventId: event.Id, From: event.Status, To: to, Reason: reason, ActorId: actorId}
+	query = `INSERT INTO event_transitions (event_id, from_status, to_status, reason, actor_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING id, created_at`
+	err := tx.QueryRowContext(ctx, query, transition.EventId, transition.From, transition.To, transition.Reason, actorId).Scan(&transition.Id, &transition.CreatedAt)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to record transition: %w", err)
+	}
+	return transition, version, nil
+}
+
+// GetTransitions returns the status history of an event, oldest first.
+func (m *EventModel) GetTransitions(ctx context.Context, eventId string) ([]*EventTransition, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetTransitions")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id, event_id, from_status, to_status, COALESCE(reason, ''), COALESCE(actor_id::text, ''), created_at
+		FROM event_transitions WHERE event_id = $1 ORDER BY created_at, id`
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	transitions := []*EventTransition{}
+	for rows.Next() {
+		t := &EventTransition{}
+		if err := rows.Scan(&t.Id, &t.EventId, &t.From, &t.To, &t.Reason, &t.ActorId, &t.CreatedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		transitions = append(transitions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(transitions)))
+	return transitions, nil
+}
@@ -5,92 +5,408 @@ import (
 	"database/sql"
 	"github.com/davidcm146/event-rest-api/internal/utils"
 	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
 )
 
+// EventModel stores events. Every change is recorded in the audit log and
+// announced in the outbox as part of the same transaction.
 type EventModel struct {
 	DB *sql.DB
 }
 
 type Event struct {
 	Id          string `json:"id"`
-	Name        string `json:"name" binding:"required,min=3"`
-	OwnerId     string `json:"ownerId" binding:"required"`
-	Description string `json:"description" binding:"required,min=10"`
-	Date        string `json:"date" binding:"required,datetime=02/01/2006"`
+	Name        string `json:"name"`
+	OwnerId     string `json:"ownerId"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
 	Location    string `json:"location"`
+	Status      string `json:"status" enums:"draft,published,cancelled,completed"`
+	Visibility  string `json:"visibility" enums:"public,unlisted,private"`
+	// AttendeesPublic lets everyone who can see the event list its
+	// attendees; otherwise only the owner can.
+	AttendeesPublic bool `json:"attendeesPublic"`
+	// Version is incremented on every change and backs the event's ETag.
+	Version int `json:"version"`
+	// DeletedAt is set while the event sits in the owner's trash. The
+	// events of a deleted account stay there, with an empty OwnerId, until
+	// they are purged.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
-func (m *EventModel) Insert(event *Event) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *EventModel) Insert(ctx context.Context, event *Event) error {
+	ctx, span := startSpan(ctx, "EventModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	formattedDate, err := utils.ParseAndFormatDate(event.Date)
 	if err != nil {
-		return err
+		return spanError(span, err)
+	}
+	if event.Visibility == "" {
+		event.Visibility = VisibilityPublic
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO events (name, owner_id, description, date, location, visibility, attendees_public)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	args := []any{event.Name, event.OwnerId, event.Description, formattedDate, event.Location, event.Visibility, event.AttendeesPublic}
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&event.Id); err != nil {
+		return spanError(span, err)
 	}
-	query := `INSERT INTO events (name, owner_id, description, date, location) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	return m.DB.QueryRowContext(ctx, query, event.Name, event.OwnerId, event.Description, formattedDate, event.Location).Scan(&event.Id)
+	stored, err := eventSnapshot(ctx, tx, event.Id)
+	if err != nil {
+		return spanError(span, err)
+	}
+	if err := saveRevision(ctx, tx, event.Id); err != nil {
+		return spanError(span, err)
+	}
+	if err := recordAudit(ctx, tx, ActionCreate, EntityEvent, event.Id, nil, stored); err != nil {
+		return spanError(span, err)
+	}
+	if err := publishEventChange(ctx, tx, TopicEventCreated, stored, ""); err != nil {
+		return spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
+	}
+	event.Version, event.Status = stored.Version, stored.Status
+	setRows(span, 1)
+	return nil
 }
 
-func (m *EventModel) GetAll() ([]*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	query := `SELECT id, name, owner_id, description, date, location FROM events`
-	rows, err := m.DB.QueryContext(ctx, query)
+// eventSnapshot reads the event, deleted or not, inside tx and locks it for
+// the rest of the transaction. It returns nil if there is no such event.
+func eventSnapshot(ctx context.Context, tx *sql.Tx, id string) (*Event, error) {
+	query := `SELECT id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public, deleted_at
+		FROM events WHERE id = $1 FOR UPDATE`
+	event := &Event{}
+	err := tx.QueryRowContext(ctx, query, id).Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date,
+		&event.Location, &event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic, &event.DeletedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// auditEvent runs change against the event inside a transaction, records
+// the difference it made in the audit log, keeps the new version as a
+// revision and announces the changed event under topic in the outbox, with
+// reason for cancellations. A missing event is reported as not found.
+// change must bump the version; it reports a stale version by returning
+// sql.ErrNoRows, which becomes a conflict wrapping ErrEditConflict.
+func (m *EventModel) auditEvent(ctx context.Context, action, id, topic, reason string, change func(tx *sql.Tx) error) (*Event, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	before, err := eventSnapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, errs.NotFound("Event not found")
+	}
+	if err := change(tx); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.E(errs.KindConflict, "The event was modified by another request", ErrEditConflict)
+		}
+		return nil, err
+	}
+	after, err := eventSnapshot(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, action, EntityEvent, id, before, after); err != nil {
+		return nil, err
+	}
+	if err := saveRevision(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := publishEventChange(ctx, tx, topic, after, reason); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// GetAll lists the events viewerId may browse: public events, private events
+// the viewer is invited to or attending, and all of the viewer's own events.
+// Drafts and unlisted events of others are left out. An empty viewerId is an
+// anonymous visitor.
+func (m *EventModel) GetAll(ctx context.Context, viewerId string) ([]*Event, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetAll")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	query := `SELECT id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public FROM events
+		WHERE deleted_at IS NULL AND (owner_id = $1 OR status <> 'draft' AND (
+			visibility = 'public' OR visibility = 'private' AND (
+				EXISTS (SELECT 1 FROM event_invitees i WHERE i.event_id = events.id AND i.user_id = $1) OR
+				EXISTS (SELECT 1 FROM attendees a WHERE a.event_id = events.id AND a.user_id = $1))))`
+	// Anonymous visitors are passed as NULL, which matches no owner, so the
+	// UUID columns are compared directly and their indexes stay usable.
+	var viewer sql.NullString
+	if viewerId != "" {
+		viewer = sql.NullString{String: viewerId, Valid: true}
+	}
+	rows, err := m.DB.QueryContext(ctx, query, viewer)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
 	defer rows.Close()
 
 	events := []*Event{}
 	for rows.Next() {
 		event := &Event{}
-		if err := rows.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location); err != nil {
-			return nil, err
+		if err := rows.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location, &event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic); err != nil {
+			return nil, spanError(span, err)
 		}
 		events = append(events, event)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(events)))
 	return events, nil
 }
 
-func (m *EventModel) Get(id string) (*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *EventModel) Get(ctx context.Context, id string) (*Event, error) {
+	ctx, span := startSpan(ctx, "EventModel.Get")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	query := `SELECT id, name, owner_id, description, date, location FROM events WHERE id = $1`
+	query := `SELECT id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public FROM events WHERE id = $1 AND deleted_at IS NULL`
 	row := m.DB.QueryRowContext(ctx, query, id)
 
 	event := Event{}
-	if err := row.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location); err != nil {
+	if err := row.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location, &event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil
+			return nil, errs.NotFound("Event not found")
 		}
-		return nil, err
+		return nil, spanError(span, err)
 	}
+	setRows(span, 1)
 	return &event, nil
 }
 
-func (m *EventModel) Update(event *Event) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// Update writes the editable fields of event, provided the stored version
+// still matches event.Version, bumps the version and keeps the result as a
+// new revision. A stale version, or an event that has been cancelled or
+// completed in the meantime, yields a conflict wrapping ErrEditConflict.
+func (m *EventModel) Update(ctx context.Context, event *Event) error {
+	ctx, span := startSpan(ctx, "EventModel.Update")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	formattedDate, err := utils.ParseAndFormatDate(event.Date)
 	if err != nil {
-		return err
+		return spanError(span, err)
 	}
-	query := `UPDATE events SET name = $1, description = $2, date = $3, location = $4 WHERE id = $5`
-	_, err = m.DB.ExecContext(ctx, query, event.Name, event.Description, formattedDate, event.Location, event.Id)
+	query := `UPDATE events SET name = $1, description = $2, date = $3, location = $4, visibility = $5, attendees_public = $6,
+		version = version + 1
+		WHERE id = $7 AND version = $8 AND deleted_at IS NULL AND status NOT IN ('cancelled', 'completed') RETURNING version`
+	args := []any{event.Name, event.Description, formattedDate, event.Location, event.Visibility, event.AttendeesPublic, event.Id, event.Version}
+	_, err = m.auditEvent(ctx, ActionUpdate, event.Id, TopicEventUpdated, "", func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, args...).Scan(&event.Version)
+	})
+	if err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Delete moves the event to the trash if its stored version still matches
+// version. Attendance is kept so the event can be restored; PurgeDeleted
+// removes it for good.
+func (m *EventModel) Delete(ctx context.Context, id string, version int) error {
+	ctx, span := startSpan(ctx, "EventModel.Delete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE events SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL RETURNING id`
+	_, err := m.auditEvent(ctx, ActionDelete, id, TopicEventDeleted, "", func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, id, version).Scan(&id)
+	})
 	if err != nil {
-		return err
+		return spanError(span, err)
 	}
+	setRows(span, 1)
 	return nil
 }
 
-func (m *EventModel) Delete(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *EventModel) CountByOwner(ctx context.Context, ownerId string) (int, error) {
+	ctx, span := startSpan(ctx, "EventModel.CountByOwner")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM events WHERE owner_id = $1 AND deleted_at IS NULL`
+	if err := m.DB.QueryRowContext(ctx, query, ownerId).Scan(&count); err != nil {
+		return 0, spanError(span, err)
+	}
+	return count, nil
+}
+
+func (m *EventModel) GetByOwner(ctx context.Context, ownerId string) ([]*Event, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetByOwner")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	query := `SELECT id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public FROM events WHERE owner_id = $1 AND deleted_at IS NULL`
+	rows, err := m.DB.QueryContext(ctx, query, ownerId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		event := &Event{}
+		if err := rows.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location, &event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic); err != nil {
+			return nil, spanError(span, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(events)))
+	return events, nil
+}
+
+// GetDeleted returns an event from the trash, provided it was deleted after
+// since.
+func (m *EventModel) GetDeleted(ctx context.Context, id string, since time.Time) (*Event, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetDeleted")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	query := `SELECT id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public, deleted_at FROM events WHERE id = $1 AND deleted_at > $2`
+	row := m.DB.QueryRowContext(ctx, query, id, since)
 
-	query := `DELETE FROM events WHERE id = $1`
-	_, err := m.DB.ExecContext(ctx, query, id)
+	event := Event{}
+	if err := row.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location, &event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic, &event.DeletedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Deleted event not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return &event, nil
+}
+
+// GetDeletedByOwner lists the owner's events deleted after since, most
+// recently deleted first.
+func (m *EventModel) GetDeletedByOwner(ctx context.Context, ownerId string, since time.Time) ([]*Event, error) {
+	ctx, span := startSpan(ctx, "EventModel.GetDeletedByOwner")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	query := `SELECT id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public, deleted_at FROM events
+		WHERE owner_id = $1 AND deleted_at > $2 ORDER BY deleted_at DESC`
+	rows, err := m.DB.QueryContext(ctx, query, ownerId, since)
 	if err != nil {
-		return err
+		return nil, spanError(span, err)
 	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		event := &Event{}
+		if err := rows.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location, &event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic, &event.DeletedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(events)))
+	return events, nil
+}
+
+// Restore takes the event out of the trash if its stored version still
+// matches event.Version, and bumps the version.
+func (m *EventModel) Restore(ctx context.Context, event *Event) error {
+	ctx, span := startSpan(ctx, "EventModel.Restore")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE events SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NOT NULL RETURNING version`
+	_, err := m.auditEvent(ctx, ActionRestore, event.Id, TopicEventRestored, "", func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, event.Id, event.Version).Scan(&event.Version)
+	})
+	if err != nil {
+		return spanError(span, err)
+	}
+	event.DeletedAt = nil
+	setRows(span, 1)
 	return nil
 }
+
+// PurgeDeleted permanently removes events deleted before the given time,
+// together with their attendees, and reports how many were removed.
+func (m *EventModel) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "EventModel.PurgeDeleted")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM events WHERE deleted_at < $1
+		RETURNING id, name, COALESCE(owner_id::text, ''), description, date, location, version, status, visibility, attendees_public, deleted_at`
+	rows, err := tx.QueryContext(ctx, query, before)
+	if err != nil {
+		return 0, spanError(span, err)
+	}
+	var purged []*Event
+	for rows.Next() {
+		event := &Event{}
+		if err := rows.Scan(&event.Id, &event.Name, &event.OwnerId, &event.Description, &event.Date, &event.Location,
+			&event.Version, &event.Status, &event.Visibility, &event.AttendeesPublic, &event.DeletedAt); err != nil {
+			rows.Close()
+			return 0, spanError(span, err)
+		}
+		purged = append(purged, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, spanError(span, err)
+	}
+
+	for _, event := range purged {
+		if err := recordAudit(ctx, tx, ActionPurge, EntityEvent, event.Id, event, nil); err != nil {
+			return 0, spanError(span, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, spanError(span, err)
+	}
+	setRows(span, int64(len(purged)))
+	return int64(len(purged)), nil
+}
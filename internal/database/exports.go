@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+)
+
+const (
+	ExportPending   = "pending"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+)
+
+type ExportModel struct {
+	DB *sql.DB
+}
+
+type Export struct {
+	Id          string     `json:"id"`
+	UserId      string     `json:"userId"`
+	Status      string     `json:"status"`
+	FilePath    string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+func (m *ExportModel) Insert(ctx context.Context, export *Export) error {
+	ctx, span := startSpan(ctx, "ExportModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO exports (user_id, status, expires_at) VALUES ($1, $2, $3) RETURNING id, created_at`
+	if err := m.DB.QueryRowContext(ctx, query, export.UserId, export.Status, export.ExpiresAt).Scan(&export.Id, &export.CreatedAt); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// CountRecords returns how many records an export of the user holds,
+// without reading them, so that small exports can be generated while the
+// client waits.
+func (m *ExportModel) CountRecords(ctx context.Context, userId string) (int, error) {
+	ctx, span := startSpan(ctx, "ExportModel.CountRecords")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT 1
+		+ (SELECT COUNT(*) FROM events WHERE owner_id = $1 AND deleted_at IS NULL)
+		+ (SELECT COUNT(*) FROM attendees WHERE user_id = $1)
+		+ (SELECT COUNT(*) FROM tokens WHERE user_id = $1)
+		+ (SELECT COUNT(*) FROM audit_log WHERE actor_id = $1)`
+	var count int
+	if err := m.DB.QueryRowContext(ctx, query, userId).Scan(&count); err != nil {
+		return 0, spanError(span, err)
+	}
+	return count, nil
+}
+
+func (m *ExportModel) Get(ctx context.Context, id string) (*Export, error) {
+	ctx, span := startSpan(ctx, "ExportModel.Get")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id, user_id, status, COALESCE(file_path, ''), COALESCE(error, ''), expires_at, created_at, completed_at FROM exports WHERE id = $1`
+	export := &Export{}
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&export.Id, &export.UserId, &export.Status, &export.FilePath, &export.Error, &export.ExpiresAt, &export.CreatedAt, &export.CompletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Export not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return export, nil
+}
+
+func (m *ExportModel) MarkCompleted(ctx context.Context, id, filePath string) error {
+	ctx, span := startSpan(ctx, "ExportModel.MarkCompleted")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE exports SET status = $1, file_path = $2, completed_at = NOW() WHERE id = $3`
+	result, err := m.DB.ExecContext(ctx, query, ExportCompleted, filePath, id)
+	if err != nil {
+		return spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return nil
+}
+
+func (m *ExportModel) MarkFailed(ctx context.Context, id, message string) error {
+	ctx, span := startSpan(ctx, "ExportModel.MarkFailed")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE exports SET status = $1, error = $2, completed_at = NOW() WHERE id = $3`
+	result, err := m.DB.ExecContext(ctx, query, ExportFailed, message, id)
+	if err != nil {
+		return spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return nil
+}
+
+// DeleteExpired removes expired exports and returns the paths of their files
+// so the caller can clean them up.
+func (m *ExportModel) DeleteExpired(ctx context.Context) ([]string, error) {
+	ctx, span := startSpan(ctx, "ExportModel.DeleteExpired")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM exports WHERE expires_at < NOW() RETURNING COALESCE(file_path, '')`
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, spanError(span, err)
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(paths)))
+	return paths, nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+)
+
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+// IdempotencyRecord is the outcome of a request made with an Idempotency-Key.
+// A record without a StatusCode belongs to a request that is still running,
+// or that died without releasing the key; once LockedUntil has passed, a
+// retry of the same request may take it over. LockToken is set by Reserve
+// and identifies the request holding the reservation.
+type IdempotencyRecord struct {
+	UserId      string
+	Key         string
+	Fingerprint []byte
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	LockToken   string
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the response has been recorded.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Matches reports whether fingerprint identifies the same request as the
+// one the record was created for.
+func (r *IdempotencyRecord) Matches(fingerprint []byte) bool {
+	return bytes.Equal(r.Fingerprint, fingerprint)
+}
+
+// Reserve claims the key for a new request. Expired records are taken over,
+// and so are unfinished reservations of the same request whose lock has run
+// out. On success record.LockToken is set. When the key is already held, the
+// existing record is returned instead and nothing is written.
+func (m *IdempotencyModel) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	ctx, span := startSpan(ctx, "IdempotencyModel.Reserve")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO idempotency_keys (user_id, key, fingerprint, locked_until, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, headers = NULL, body = NULL,
+			lock_token = gen_random_uuid(), locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at,
+			created_at = CURRENT_TIMESTAMP
+		WHERE idempotency_keys.expires_at < NOW() OR (idempotency_keys.status_code IS NULL
+			AND idempotency_keys.locked_until < NOW() AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+		RETURNING lock_token`
+	err := m.DB.QueryRowContext(ctx, query, record.UserId, record.Key, record.Fingerprint, record.LockedUntil, record.ExpiresAt).Scan(&record.LockToken)
+	if err == nil {
+		setRows(span, 1)
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, spanError(span, err)
+	}
+
+	query = `SELECT fingerprint, COALESCE(status_code, 0), headers, body, locked_until, expires_at FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	existing := &IdempotencyRecord{UserId: record.UserId, Key: record.Key}
+	var header []byte
+	err = m.DB.QueryRowContext(ctx, query, record.UserId, record.Key).Scan(&existing.Fingerprint, &existing.StatusCode, &header, &existing.Body, &existing.LockedUntil, &existing.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Released between the two statements; the client may retry.
+			return nil, errs.Conflict("A request with this idempotency key is already in progress")
+		}
+		return nil, spanError(span, err)
+	}
+	if header != nil {
+		if err := json.Unmarshal(header, &existing.Header); err != nil {
+			return nil, spanError(span, err)
+		}
+	}
+	setRows(span, 1)
+	return existing, nil
+}
+
+// Complete records the response of a reserved request so retries can replay
+// it. It returns ErrLockLost if the reservation has been taken over.
+func (m *IdempotencyModel) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	ctx, span := startSpan(ctx, "IdempotencyModel.Complete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return spanError(span, err)
+	}
+	query := `UPDATE idempotency_keys SET status_code = $1, headers = $2, body = $3
+		WHERE user_id = $4 AND key = $5 AND lock_token = $6 AND status_code IS NULL`
+	// lib/pq sends []byte as bytea, which JSONB does not accept.
+	result, err := m.DB.ExecContext(ctx, query, record.StatusCode, string(header), record.Body, record.UserId, record.Key, record.LockToken)
+	if err != nil {
+		return spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	if rows == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release gives up a reservation whose request failed, so that a retry runs
+// the request again. It returns ErrLockLost if the reservation has been
+// taken over.
+func (m *IdempotencyModel) Release(ctx context.Context, record *IdempotencyRecord) error {
+	ctx, span := startSpan(ctx, "IdempotencyModel.Release")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND lock_token = $3 AND status_code IS NULL`
+	result, err := m.DB.ExecContext(ctx, query, record.UserId, record.Key, record.LockToken)
+	if err != nil {
+		return spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	if rows == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// DeleteExpired removes records past their expiry and reports how many were
+// deleted.
+func (m *IdempotencyModel) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, "IdempotencyModel.DeleteExpired")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at < NOW()`
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return rows, nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Event visibilities. Unlisted events are reachable by anyone with the link
+// but left out of listings; private events are only visible to their owner,
+// invitees and attendees.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+type InviteeModel struct {
+	DB *sql.DB
+}
+
+func (m *InviteeModel) Insert(ctx context.Context, eventId, userId string) error {
+	ctx, span := startSpan(ctx, "InviteeModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO event_invitees (event_id, user_id) VALUES ($1, $2)`
+	if _, err := m.DB.ExecContext(ctx, query, eventId, userId); err != nil {
+		return spanError(span, mapError(err))
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Delete removes an invitation and reports whether there was one.
+func (m *InviteeModel) Delete(ctx context.Context, eventId, userId string) (bool, error) {
+	ctx, span := startSpan(ctx, "InviteeModel.Delete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM event_invitees WHERE event_id = $1 AND user_id = $2`
+	result, err := m.DB.ExecContext(ctx, query, eventId, userId)
+	if err != nil {
+		return false, spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return rows > 0, nil
+}
+
+func (m *InviteeModel) GetByEventId(ctx context.Context, eventId string) ([]*User, error) {
+	ctx, span := startSpan(ctx, "InviteeModel.GetByEventId")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT u.id, u.name, u.email FROM event_invitees i
+		JOIN users u ON i.user_id = u.id
+		WHERE i.event_id = $1 ORDER BY i.created_at`
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user := &User{}
+		if err := rows.Scan(&user.Id, &user.Name, &user.Email); err != nil {
+			return nil, spanError(span, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(users)))
+	return users, nil
+}
+
+// IsInvolved reports whether the user is invited to or attending the event,
+// which grants access to private events.
+func (m *InviteeModel) IsInvolved(ctx context.Context, eventId, userId string) (bool, error) {
+	ctx, span := startSpan(ctx, "InviteeModel.IsInvolved")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT EXISTS (SELECT 1 FROM event_invitees WHERE event_id = $1 AND user_id = $2)
+		OR EXISTS (SELECT 1 FROM attendees WHERE event_id = $1 AND user_id = $2)`
+	var involved bool
+	if err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&involved); err != nil {
+		return false, spanError(span, err)
+	}
+	return involved, nil
+}
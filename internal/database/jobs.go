@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Job statuses. A job is running while a worker holds it, and dead once it
+// has run out of attempts.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// JobModel is a queue of background work. Workers claim due jobs with
+// FOR UPDATE SKIP LOCKED, so any number of processes can share it.
+type JobModel struct {
+	DB *sql.DB
+}
+
+// Job is a unit of background work of some kind, described by its JSON
+// arguments.
+type Job struct {
+	Id          string
+	Kind        string
+	Args        json.RawMessage
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	// UniqueKey, when set, keeps the same job from being enqueued twice.
+	UniqueKey  string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+const jobColumns = `id, kind, args, status, attempts, max_attempts, run_at,
+	COALESCE(last_error, ''), COALESCE(unique_key, ''), created_at, finished_at`
+
+func scanJob(row interface{ Scan(...any) error }, job *Job) error {
+	var args []byte
+	err := row.Scan(&job.Id, &job.Kind, &args, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.LastError, &job.UniqueKey, &job.CreatedAt, &job.FinishedAt)
+	if err != nil {
+		return err
+	}
+	job.Args = args
+	return nil
+}
+
+// Enqueue adds a pending job that is due at job.RunAt, or immediately when
+// it is zero. It reports false, and leaves job untouched, when a job with
+// the same unique key already exists.
+func (m *JobModel) Enqueue(ctx context.Context, job *Job) (bool, error) {
+	ctx, span := startSpan(ctx, "JobModel.Enqueue")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO jobs (kind, args, max_attempts, run_at, unique_key)
+		VALUES ($1, $2, $3, COALESCE($4, CURRENT_TIMESTAMP), NULLIF($5, ''))
+		ON CONFLICT (unique_key) DO NOTHING
+		RETURNING ` + jobColumns
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+	// lib/pq sends []byte as bytea, which JSONB does not accept.
+	row := m.DB.QueryRowContext(ctx, query, job.Kind, string(job.Args), job.MaxAttempts, runAt, job.UniqueKey)
+	if err := scanJob(row, job); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, spanError(span, err)
+	}
+	setRows(span, 1)
+	return true, nil
+}
+
+// Claim returns up to limit jobs of the given kind that are due at now,
+// marks them as running and counts the attempt. A claim holds for lease;
+// running jobs whose lease ran out, because their worker died, are claimed
+// again. Rows locked by a concurrent claim are skipped.
+func (m *JobModel) Claim(ctx context.Context, kind string, now time.Time, lease time.Duration, limit int) ([]*Job, error) {
+	ctx, span := startSpan(ctx, "JobModel.Claim")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `WITH due AS (
+			SELECT id FROM jobs
+			WHERE kind = $1 AND ((status = 'pending' AND run_at <= $2) OR (status = 'running' AND locked_until <= $2))
+			ORDER BY run_at LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE jobs j SET status = 'running', attempts = j.attempts + 1, locked_until = $3
+		FROM due
+		WHERE j.id = due.id
+		RETURNING j.id, j.kind, j.args, j.status, j.attempts, j.max_attempts, j.run_at,
+			COALESCE(j.last_error, ''), COALESCE(j.unique_key, ''), j.created_at, j.finished_at`
+	rows, err := m.DB.QueryContext(ctx, query, kind, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		job := &Job{}
+		if err := scanJob(rows, job); err != nil {
+			return nil, spanError(span, err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(jobs)))
+	return jobs, nil
+}
+
+// Complete records that a job succeeded.
+func (m *JobModel) Complete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "JobModel.Complete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE jobs SET status = 'succeeded', locked_until = NULL, last_error = NULL, finished_at = NOW() WHERE id = $1`
+	if _, err := m.DB.ExecContext(ctx, query, id); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Retry records a failed attempt and when to run the job again.
+func (m *JobModel) Retry(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	ctx, span := startSpan(ctx, "JobModel.Retry")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE jobs SET status = 'pending', run_at = $1, locked_until = NULL, last_error = $2 WHERE id = $3`
+	if _, err := m.DB.ExecContext(ctx, query, runAt, lastError, id); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Bury records the last failure of a job that ran out of attempts. Dead jobs
+// are kept for inspection and never run again.
+func (m *JobModel) Bury(ctx context.Context, id, lastError string) error {
+	ctx, span := startSpan(ctx, "JobModel.Bury")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE jobs SET status = 'dead', locked_until = NULL, last_error = $1, finished_at = NOW() WHERE id = $2`
+	if _, err := m.DB.ExecContext(ctx, query, lastError, id); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// DeleteSucceeded removes jobs that succeeded before the given time and
+// reports how many were removed.
+func (m *JobModel) DeleteSucceeded(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "JobModel.DeleteSucceeded")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM jobs WHERE status = 'succeeded' AND finished_at < $1`
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return rows, nil
+}
@@ -0,0 +1,3 @@
+ALTER TABLE events
+    DROP CONSTRAINT IF EXISTS events_owner_id_fkey,
+    ADD CONSTRAINT events_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
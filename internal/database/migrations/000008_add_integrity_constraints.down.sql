@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS exports_user_id_idx;
+DROP INDEX IF EXISTS tokens_user_id_idx;
+DROP INDEX IF EXISTS attendees_user_id_idx;
+DROP INDEX IF EXISTS events_owner_id_idx;
+
+ALTER TABLE attendees DROP CONSTRAINT IF EXISTS attendees_event_id_user_id_key;
+
+-- The id defaults stay on gen_random_uuid(): it is built into Postgres 13+
+-- and does not need the uuid-ossp extension.
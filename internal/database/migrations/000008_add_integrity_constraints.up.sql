@@ -0,0 +1,19 @@
+ALTER TABLE users ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE events ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE attendees ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE exports ALTER COLUMN id SET DEFAULT gen_random_uuid();
+
+-- Remove duplicates created by the old check-then-insert race, keeping the
+-- earliest row, before enforcing uniqueness.
+DELETE FROM attendees a
+    USING attendees b
+    WHERE a.event_id = b.event_id
+      AND a.user_id = b.user_id
+      AND (a.created_at, a.id) > (b.created_at, b.id);
+
+ALTER TABLE attendees ADD CONSTRAINT attendees_event_id_user_id_key UNIQUE (event_id, user_id);
+
+CREATE INDEX IF NOT EXISTS events_owner_id_idx ON events (owner_id);
+CREATE INDEX IF NOT EXISTS attendees_user_id_idx ON attendees (user_id);
+CREATE INDEX IF NOT EXISTS tokens_user_id_idx ON tokens (user_id);
+CREATE INDEX IF NOT EXISTS exports_user_id_idx ON exports (user_id);
@@ -0,0 +1,6 @@
+ALTER TABLE events
+    ALTER COLUMN name TYPE TEXT,
+    ALTER COLUMN description TYPE TEXT,
+    ALTER COLUMN location TYPE TEXT;
+
+ALTER TABLE users ALTER COLUMN email TYPE TEXT;
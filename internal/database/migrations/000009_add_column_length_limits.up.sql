@@ -0,0 +1,6 @@
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(254);
+
+ALTER TABLE events
+    ALTER COLUMN name TYPE VARCHAR(200),
+    ALTER COLUMN description TYPE VARCHAR(5000),
+    ALTER COLUMN location TYPE VARCHAR(200);
@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
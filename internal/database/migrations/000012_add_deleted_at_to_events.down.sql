@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS events_deleted_at_idx;
+
+DELETE FROM events WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE events DROP COLUMN deleted_at;
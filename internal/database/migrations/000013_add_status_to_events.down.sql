@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS event_transitions;
+
+ALTER TABLE events DROP COLUMN status;
@@ -0,0 +1,19 @@
+-- Existing events are already live, so they start out published; new events
+-- are drafts until their owner publishes them.
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'published', 'cancelled', 'completed'));
+ALTER TABLE events ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE TABLE IF NOT EXISTS event_transitions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT,
+    actor_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS event_transitions_event_id_idx ON event_transitions (event_id);
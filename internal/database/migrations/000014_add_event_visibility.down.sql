@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS event_invitees;
+
+ALTER TABLE events
+    DROP COLUMN attendees_public,
+    DROP COLUMN visibility;
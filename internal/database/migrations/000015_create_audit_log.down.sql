@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,21 @@
+-- Revisions are numbered by the event version they produced, so a revision
+-- and the ETag served for that version refer to the same content.
+CREATE TABLE IF NOT EXISTS event_revisions (
+    event_id UUID NOT NULL,
+    version INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    date DATE NOT NULL,
+    location TEXT NOT NULL,
+    visibility TEXT NOT NULL,
+    attendees_public BOOLEAN NOT NULL,
+    editor_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, version),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+-- Existing events start their history with their current content.
+INSERT INTO event_revisions (event_id, version, name, description, date, location, visibility, attendees_public)
+SELECT id, version, name, description, date, location, visibility, attendees_public FROM events;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS webhook_deliveries_message_id_key;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS message_id;
+DROP TABLE IF EXISTS outbox_handled;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic TEXT NOT NULL,
+    aggregate_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- Subscribers that have handled a message, so that a retry only reaches the
+-- ones that failed.
+CREATE TABLE IF NOT EXISTS outbox_handled (
+    message_id UUID NOT NULL,
+    subscriber TEXT NOT NULL,
+    handled_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, subscriber),
+    FOREIGN KEY (message_id) REFERENCES outbox(id) ON DELETE CASCADE
+);
+
+-- Webhook deliveries remember the outbox message they were queued for, so a
+-- message relayed twice does not notify a webhook twice. Replays have none.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS message_id UUID;
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_message_id_key ON webhook_deliveries (webhook_id, message_id);
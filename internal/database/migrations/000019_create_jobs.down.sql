@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+DROP TABLE IF EXISTS jobs;
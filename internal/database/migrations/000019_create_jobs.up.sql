@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    args JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL CHECK (max_attempts > 0),
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    -- A job with a unique key is only ever enqueued once.
+    unique_key TEXT UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS jobs_pending_idx ON jobs (kind, run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS jobs_running_idx ON jobs (kind, locked_until) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS jobs_finished_at_idx ON jobs (finished_at) WHERE status = 'succeeded';
+
+-- Webhook deliveries are sent by jobs now rather than polled directly.
+DROP INDEX IF EXISTS webhook_deliveries_due_idx;
+
+-- Hand deliveries that were still pending over to the queue, with the default
+-- WEBHOOK_MAX_ATTEMPTS.
+INSERT INTO jobs (kind, args, max_attempts, run_at, unique_key)
+SELECT 'webhook.deliver', jsonb_build_object('deliveryId', id), 8, COALESCE(next_attempt_at, CURRENT_TIMESTAMP), 'webhook.deliver:' || id
+FROM webhook_deliveries WHERE status = 'pending'
+ON CONFLICT (unique_key) DO NOTHING;
@@ -0,0 +1,6 @@
+DELETE FROM events WHERE owner_id IS NULL;
+
+ALTER TABLE events
+    DROP CONSTRAINT IF EXISTS events_owner_id_fkey,
+    ADD CONSTRAINT events_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    ALTER COLUMN owner_id SET NOT NULL;
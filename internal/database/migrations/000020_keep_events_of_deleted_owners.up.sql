@@ -0,0 +1,7 @@
+-- When an owner deletes their account without handing their events over,
+-- the events are cancelled and moved to the trash. They stay there, without
+-- an owner, until they are purged.
+ALTER TABLE events
+    ALTER COLUMN owner_id DROP NOT NULL,
+    DROP CONSTRAINT IF EXISTS events_owner_id_fkey,
+    ADD CONSTRAINT events_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS lock_token, DROP COLUMN IF EXISTS locked_until;
@@ -0,0 +1,7 @@
+-- An unfinished reservation only blocks its key until locked_until, after
+-- which a retry may take it over. Reservations already in the table can be
+-- taken over straight away. lock_token identifies the current holder, so a
+-- request that lost its reservation cannot record or release it.
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS lock_token UUID NOT NULL DEFAULT gen_random_uuid();
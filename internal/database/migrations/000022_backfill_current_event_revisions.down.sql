@@ -0,0 +1,2 @@
+-- Backfilled revisions cannot be told apart from saved ones, so they stay.
+SELECT 1;
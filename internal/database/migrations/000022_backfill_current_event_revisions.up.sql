@@ -0,0 +1,6 @@
+-- Status changes, trash and restore used to bump the version without saving
+-- a revision. The content of those versions is gone, but every event gets a
+-- revision for the version it is at now.
+INSERT INTO event_revisions (event_id, version, name, description, date, location, visibility, attendees_public)
+SELECT id, version, name, description, date, location, visibility, attendees_public FROM events
+ON CONFLICT (event_id, version) DO NOTHING;
@@ -0,0 +1,8 @@
+DELETE FROM webhooks WHERE organization_id IS NOT NULL;
+ALTER TABLE webhooks
+    DROP CONSTRAINT IF EXISTS webhooks_owner_check,
+    DROP COLUMN IF EXISTS organization_id,
+    ALTER COLUMN user_id SET NOT NULL;
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS organization_members_user_id_idx ON organization_members (user_id);
+
+-- A webhook belongs either to a user or to an organization.
+ALTER TABLE webhooks
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    ADD CONSTRAINT webhooks_owner_check CHECK ((user_id IS NULL) <> (organization_id IS NULL));
+
+CREATE INDEX IF NOT EXISTS webhooks_organization_id_idx ON webhooks (organization_id);
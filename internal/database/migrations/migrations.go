@@ -0,0 +1,130 @@
+// Package migrations embeds the SQL migrations so every binary carries its
+// own schema and does not depend on the working directory.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// advisoryLockKey identifies the lock held while applying migrations so that
+// several API replicas booting at once apply them one at a time.
+const advisoryLockKey = 7_146_214_001
+
+// Source returns a golang-migrate source reading the embedded files.
+func Source() (source.Driver, error) {
+	return iofs.New(FS, ".")
+}
+
+// New builds a migrator for db using the embedded migrations. Closing the
+// returned migrator also closes db.
+func New(db *sql.DB) (*migrate.Migrate, error) {
+	src, err := Source()
+	if err != nil {
+		return nil, err
+	}
+	instance, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return migrate.NewWithInstance("iofs", src, "postgres", instance)
+}
+
+// Latest returns the highest migration version shipped with this build.
+func Latest() (uint, error) {
+	src, err := Source()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// Up applies all pending migrations while holding a Postgres advisory lock,
+// so it is safe to call from every replica on startup. It returns the
+// resulting schema version. Unlike New, it leaves db open.
+func Up(ctx context.Context, db *sql.DB) (uint, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		return 0, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	// Advisory locks belong to the session, so release it before the
+	// connection goes back to the pool, which closing the migrator does.
+	unlock := sync.OnceValue(func() error {
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockKey)
+		return err
+	})
+	defer unlock()
+
+	src, err := Source()
+	if err != nil {
+		return 0, err
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		src.Close()
+		return 0, err
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		unlock()
+		return 0, errors.Join(err, src.Close(), driver.Close())
+	}
+
+	version, err := apply(m)
+	unlockErr := unlock()
+	srcErr, dbErr := m.Close()
+	if err != nil {
+		return 0, err
+	}
+	if err := errors.Join(unlockErr, srcErr, dbErr); err != nil {
+		return 0, fmt.Errorf("failed to close migrator: %w", err)
+	}
+	return version, nil
+}
+
+// apply runs the pending migrations of m and reports the version reached.
+func apply(m *migrate.Migrate) (uint, error) {
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, err
+	}
+
+	version, _, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, err
+	}
+	return version, nil
+}
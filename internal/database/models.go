@@ -1,17 +1,156 @@
 package database
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The store interfaces describe what the handlers need from each model so
+// they can be exercised against in-memory implementations in tests.
+
+type UserStore interface {
+	Insert(ctx context.Context, user *User) error
+	GetById(ctx context.Context, id string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	UpdatePassword(ctx context.Context, id, hashedPassword string) error
+	Delete(ctx context.Context, id, transferTo string) error
+}
+
+type EventStore interface {
+	Insert(ctx context.Context, event *Event) error
+	GetAll(ctx context.Context, viewerId string) ([]*Event, error)
+	Get(ctx context.Context, id string) (*Event, error)
+	Update(ctx context.Context, event *Event) error
+	Delete(ctx context.Context, id string, version int) error
+	CountByOwner(ctx context.Context, ownerId string) (int, error)
+	GetByOwner(ctx context.Context, ownerId string) ([]*Event, error)
+	GetDeleted(ctx context.Context, id string, since time.Time) (*Event, error)
+	GetDeletedByOwner(ctx context.Context, ownerId string, since time.Time) ([]*Event, error)
+	Restore(ctx context.Context, event *Event) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	Transition(ctx context.Context, event *Event, to, reason, actorId string) (*EventTransition, error)
+	GetTransitions(ctx context.Context, eventId string) ([]*EventTransition, error)
+	GetRevisions(ctx context.Context, eventId string) ([]*EventRevision, error)
+	GetRevision(ctx context.Context, eventId string, version int) (*EventRevision, error)
+}
+
+type AttendeeStore interface {
+	Insert(ctx context.Context, attendee *Attendee) (*Attendee, error)
+	GetByEventAndAttendee(ctx context.Context, eventId, userId string) (*Attendee, error)
+	GetAttendeesByEventId(ctx context.Context, eventId string) ([]*User, error)
+	GetEventsByAttendeeId(ctx context.Context, userId string) ([]*Event, error)
+	GetAttendance(ctx context.Context, userId string) ([]*Event, error)
+	Delete(ctx context.Context, userId, eventId string) error
+}
+
+type InviteeStore interface {
+	Insert(ctx context.Context, eventId, userId string) error
+	Delete(ctx context.Context, eventId, userId string) (bool, error)
+	GetByEventId(ctx context.Context, eventId string) ([]*User, error)
+	IsInvolved(ctx context.Context, eventId, userId string) (bool, error)
+}
+
+type OrganizationStore interface {
+	Insert(ctx context.Context, organization *Organization, adminId string) error
+	Get(ctx context.Context, id, userId string) (*Organization, error)
+	GetByMember(ctx context.Context, userId string) ([]*Organization, error)
+	GetMembers(ctx context.Context, organizationId string) ([]*Member, error)
+	AddMember(ctx context.Context, organizationId, userId, role string) error
+	RemoveMember(ctx context.Context, organizationId, userId string) (bool, error)
+}
+
+type AuditStore interface {
+	Query(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+type WebhookStore interface {
+	Insert(ctx context.Context, webhook *Webhook) error
+	Get(ctx context.Context, id string) (*Webhook, error)
+	GetByUser(ctx context.Context, userId string) ([]*Webhook, error)
+	GetByOrganization(ctx context.Context, organizationId string) ([]*Webhook, error)
+	Delete(ctx context.Context, id string) error
+	Enqueue(ctx context.Context, messageId, ownerId, eventType string, payload []byte) ([]string, error)
+	Replay(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, webhookId string, limit int) ([]*WebhookDelivery, error)
+	GetDelivery(ctx context.Context, webhookId, id string) (*WebhookDelivery, error)
+	GetPendingDelivery(ctx context.Context, id string) (*WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *WebhookDelivery) error
+}
+
+type OutboxStore interface {
+	ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*OutboxMessage, error)
+	MarkHandled(ctx context.Context, id, subscriber string) error
+	MarkPublished(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, next time.Time, lastError string) error
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type JobStore interface {
+	Enqueue(ctx context.Context, job *Job) (bool, error)
+	Claim(ctx context.Context, kind string, now time.Time, lease time.Duration, limit int) ([]*Job, error)
+	Complete(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, runAt time.Time, lastError string) error
+	Bury(ctx context.Context, id, lastError string) error
+	DeleteSucceeded(ctx context.Context, before time.Time) (int64, error)
+}
+
+type TokenStore interface {
+	New(ctx context.Context, userId string, ttl time.Duration, scope string) (*Token, error)
+	NewWithPayload(ctx context.Context, userId string, ttl time.Duration, scope, payload string) (*Token, error)
+	Consume(ctx context.Context, scope, plaintext string) (*Token, error)
+	ConsumeForUser(ctx context.Context, scope, plaintext, userId string) (*Token, error)
+	DeleteAllForUser(ctx context.Context, scope, userId string) error
+	GetAllForUser(ctx context.Context, userId string) ([]*TokenInfo, error)
+}
+
+type ExportStore interface {
+	Insert(ctx context.Context, export *Export) error
+	CountRecords(ctx context.Context, userId string) (int, error)
+	Get(ctx context.Context, id string) (*Export, error)
+	MarkCompleted(ctx context.Context, id, filePath string) error
+	MarkFailed(ctx context.Context, id, message string) error
+	DeleteExpired(ctx context.Context) ([]string, error)
+}
+
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	Release(ctx context.Context, record *IdempotencyRecord) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
 
 type Models struct {
-	Users     UserModel
-	Events    EventModel
-	Attendees AttendeeModel
+	Users     UserStore
+	Events    EventStore
+	Attendees AttendeeStore
+	Invitees  InviteeStore
+	Tokens    TokenStore
+	Exports   ExportStore
+	Audit     AuditStore
+	Webhooks  WebhookStore
+	Outbox    OutboxStore
+	Jobs      JobStore
+
+	IdempotencyKeys IdempotencyStore
+	Organizations   OrganizationStore
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:     UserModel{DB: db},
-		Events:    EventModel{DB: db},
-		Attendees: AttendeeModel{DB: db},
+		Users:     &UserModel{DB: db},
+		Events:    &EventModel{DB: db},
+		Attendees: &AttendeeModel{DB: db},
+		Invitees:  &InviteeModel{DB: db},
+		Tokens:    &TokenModel{DB: db},
+		Exports:   &ExportModel{DB: db},
+		Audit:     &AuditModel{DB: db},
+		Webhooks:  &WebhookModel{DB: db},
+		Outbox:    &OutboxModel{DB: db},
+		Jobs:      &JobModel{DB: db},
+
+		IdempotencyKeys: &IdempotencyModel{DB: db},
+		Organizations:   &OrganizationModel{DB: db},
 	}
 }
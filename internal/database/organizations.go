@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+)
+
+// Organization roles. Admins manage the members and webhooks of an
+// organization; members only belong to it.
+const (
+	OrganizationAdmin  = "admin"
+	OrganizationMember = "member"
+)
+
+type OrganizationModel struct {
+	DB *sql.DB
+}
+
+// Organization groups users so that changes to the events any of them owns
+// can be subscribed to together.
+type Organization struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Role is that of the user the organization was loaded for, if any.
+	Role      string    `json:"role,omitempty" enums:"admin,member"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Member struct {
+	UserId    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role" enums:"admin,member"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Insert creates an organization with adminId as its first admin.
+func (m *OrganizationModel) Insert(ctx context.Context, organization *Organization, adminId string) error {
+	ctx, span := startSpan(ctx, "OrganizationModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at`
+	if err := tx.QueryRowContext(ctx, query, organization.Name).Scan(&organization.Id, &organization.CreatedAt); err != nil {
+		return spanError(span, err)
+	}
+	query = `INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, organization.Id, adminId, OrganizationAdmin); err != nil {
+		return spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
+	}
+	organization.Role = OrganizationAdmin
+	setRows(span, 1)
+	return nil
+}
+
+// Get returns an organization together with the role userId has in it.
+// Organizations the user is not a member of are reported as missing.
+func (m *OrganizationModel) Get(ctx context.Context, id, userId string) (*Organization, error) {
+	ctx, span := startSpan(ctx, "OrganizationModel.Get")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT o.id, o.name, m.role, o.created_at FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE o.id::text = $1 AND m.user_id = $2`
+	organization := &Organization{}
+	err := m.DB.QueryRowContext(ctx, query, id, userId).Scan(&organization.Id, &organization.Name, &organization.Role, &organization.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Organization not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return organization, nil
+}
+
+// GetByMember returns the organizations userId belongs to, with their role.
+func (m *OrganizationModel) GetByMember(ctx context.Context, userId string) ([]*Organization, error) {
+	ctx, span := startSpan(ctx, "OrganizationModel.GetByMember")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT o.id, o.name, m.role, o.created_at FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1 ORDER BY o.created_at, o.id`
+	rows, err := m.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	organizations := []*Organization{}
+	for rows.Next() {
+		organization := &Organization{}
+		if err := rows.Scan(&organization.Id, &organization.Name, &organization.Role, &organization.CreatedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		organizations = append(organizations, organization)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(organizations)))
+	return organizations, nil
+}
+
+func (m *OrganizationModel) GetMembers(ctx context.Context, organizationId string) ([]*Member, error) {
+	ctx, span := startSpan(ctx, "OrganizationModel.GetMembers")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT u.id, u.name, u.email, m.role, m.created_at FROM organization_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.organization_id = $1 ORDER BY m.created_at, u.id`
+	rows, err := m.DB.QueryContext(ctx, query, organizationId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		member := &Member{}
+		if err := rows.Scan(&member.UserId, &member.Name, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(members)))
+	return members, nil
+}
+
+// AddMember adds userId to an organization with the given role. Adding a
+// member twice is a conflict wrapping ErrDuplicate.
+func (m *OrganizationModel) AddMember(ctx context.Context, organizationId, userId, role string) error {
+	ctx, span := startSpan(ctx, "OrganizationModel.AddMember")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)`
+	if _, err := m.DB.ExecContext(ctx, query, organizationId, userId, role); err != nil {
+		return spanError(span, mapError(err))
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// RemoveMember takes userId out of an organization and reports whether they
+// were a member. The last admin cannot be removed, as nobody would be left
+// to manage the organization.
+func (m *OrganizationModel) RemoveMember(ctx context.Context, organizationId, userId string) (bool, error) {
+	ctx, span := startSpan(ctx, "OrganizationModel.RemoveMember")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	// Locking the organization serialises removals, so two admins cannot
+	// remove each other at the same time.
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, organizationId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, spanError(span, err)
+	}
+
+	var role string
+	query := `DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2 RETURNING role`
+	err = tx.QueryRowContext(ctx, query, organizationId, userId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, spanError(span, err)
+	}
+	if role == OrganizationAdmin {
+		var admins int
+		query = `SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2`
+		if err := tx.QueryRowContext(ctx, query, organizationId, OrganizationAdmin).Scan(&admins); err != nil {
+			return false, spanError(span, err)
+		}
+		if admins == 0 {
+			return false, errs.Conflict("An organization must keep at least one admin")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, spanError(span, err)
+	}
+	setRows(span, 1)
+	return true, nil
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Domain events written to the outbox.
+const (
+	TopicEventCreated    = "event.created"
+	TopicEventUpdated    = "event.updated"
+	TopicEventPublished  = "event.published"
+	TopicEventCancelled  = "event.cancelled"
+	TopicEventCompleted  = "event.completed"
+	TopicEventDeleted    = "event.deleted"
+	TopicEventRestored   = "event.restored"
+	TopicAttendeeAdded   = "attendee.added"
+	TopicAttendeeRemoved = "attendee.removed"
+)
+
+// transitionTopics maps the status an event moves to onto the domain event
+// announcing it.
+var transitionTopics = map[string]string{
+	EventPublished: TopicEventPublished,
+	EventCancelled: TopicEventCancelled,
+	EventCompleted: TopicEventCompleted,
+}
+
+// OutboxModel stores domain events until they have been relayed to every
+// subscriber. Messages are written in the same transaction as the change
+// they describe, so nothing is announced for a change that rolled back.
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// OutboxMessage is a domain event waiting to be relayed. Id is unique per
+// change and stays the same when the message is relayed again, so
+// subscribers can use it to recognise duplicates.
+type OutboxMessage struct {
+	Id          string
+	Topic       string
+	AggregateId string
+	Payload     json.RawMessage
+	Attempts    int
+	CreatedAt   time.Time
+	// Handled lists the subscribers that have already processed the
+	// message.
+	Handled []string
+}
+
+// EventChanged is the payload of the event.* topics.
+type EventChanged struct {
+	Event *Event `json:"event"`
+	// Reason is the organizer's reason for a cancellation.
+	Reason string `json:"reason,omitempty"`
+	// Attendees are the people attending a cancelled or deleted event.
+	Attendees []*User `json:"attendees,omitempty"`
+}
+
+// AttendeeChanged is the payload of the attendee.* topics.
+type AttendeeChanged struct {
+	Attendee *Attendee `json:"attendee"`
+	// OwnerId is the owner of the event.
+	OwnerId string `json:"ownerId"`
+}
+
+// publish writes a message to the outbox as part of tx, so that it is only
+// relayed if the change itself is committed.
+func publish(ctx context.Context, tx *sql.Tx, topic, aggregateId string, payload any) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO outbox (topic, aggregate_id, payload) VALUES ($1, $2, $3)`
+	// lib/pq sends []byte as bytea, which JSONB does not accept.
+	if _, err := tx.ExecContext(ctx, query, topic, aggregateId, string(encoded)); err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return nil
+}
+
+// publishEventChange announces a change to event under topic. Attendees are
+// included for cancellations and deletions so they can be told about it.
+func publishEventChange(ctx context.Context, tx *sql.Tx, topic string, event *Event, reason string) error {
+	change := EventChanged{Event: event, Reason: reason}
+	if topic == TopicEventCancelled || topic == TopicEventDeleted {
+		attendees, err := eventAttendees(ctx, tx, event.Id)
+		if err != nil {
+			return err
+		}
+		change.Attendees = attendees
+	}
+	return publish(ctx, tx, topic, event.Id, change)
+}
+
+// eventAttendees lists the attendees of an event inside tx, whether or not
+// the event is deleted.
+func eventAttendees(ctx context.Context, tx *sql.Tx, eventId string) ([]*User, error) {
+	query := `SELECT u.id, u.name, u.email FROM attendees a JOIN users u ON a.user_id = u.id WHERE a.event_id = $1`
+	rows, err := tx.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attendees []*User
+	for rows.Next() {
+		user := &User{}
+		if err := rows.Scan(&user.Id, &user.Name, &user.Email); err != nil {
+			return nil, err
+		}
+		attendees = append(attendees, user)
+	}
+	return attendees, rows.Err()
+}
+
+// publishAttendeeChange announces that attendee joined or left an event.
+func publishAttendeeChange(ctx context.Context, tx *sql.Tx, topic string, attendee *Attendee) error {
+	change := AttendeeChanged{Attendee: attendee}
+	query := `SELECT COALESCE(owner_id::text, '') FROM events WHERE id = $1`
+	if err := tx.QueryRowContext(ctx, query, attendee.EventId).Scan(&change.OwnerId); err != nil {
+		return err
+	}
+	return publish(ctx, tx, topic, attendee.EventId, change)
+}
+
+// ClaimPending returns up to limit messages, oldest first, that are due at
+// now and have not been relayed to every subscriber yet, and pushes their
+// next attempt back by lease so that other replicas leave them alone in the
+// meantime. Rows locked by a concurrent claim are skipped.
+func (m *OutboxModel) ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*OutboxMessage, error) {
+	ctx, span := startSpan(ctx, "OutboxModel.ClaimPending")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `WITH due AS (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND next_attempt_at <= $1
+			ORDER BY created_at LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox o SET next_attempt_at = $2
+		FROM due
+		WHERE o.id = due.id
+		RETURNING o.id, o.topic, o.aggregate_id, o.payload, o.attempts, o.created_at,
+			ARRAY(SELECT subscriber FROM outbox_handled h WHERE h.message_id = o.id)`
+	rows, err := m.DB.QueryContext(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	messages := []*OutboxMessage{}
+	for rows.Next() {
+		msg := &OutboxMessage{}
+		var payload []byte
+		err := rows.Scan(&msg.Id, &msg.Topic, &msg.AggregateId, &payload, &msg.Attempts, &msg.CreatedAt, pq.Array(&msg.Handled))
+		if err != nil {
+			return nil, spanError(span, err)
+		}
+		msg.Payload = payload
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	// The claim is applied in created_at order but returned in any order.
+	slices.SortFunc(messages, func(a, b *OutboxMessage) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	setRows(span, int64(len(messages)))
+	return messages, nil
+}
+
+// MarkHandled records that subscriber has processed the message.
+func (m *OutboxModel) MarkHandled(ctx context.Context, id, subscriber string) error {
+	ctx, span := startSpan(ctx, "OutboxModel.MarkHandled")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO outbox_handled (message_id, subscriber) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if _, err := m.DB.ExecContext(ctx, query, id, subscriber); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// MarkPublished records that every subscriber has processed the message.
+func (m *OutboxModel) MarkPublished(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "OutboxModel.MarkPublished")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`
+	if _, err := m.DB.ExecContext(ctx, query, id); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Retry records a failed attempt to relay the message and when to try
+// again.
+func (m *OutboxModel) Retry(ctx context.Context, id string, next time.Time, lastError string) error {
+	ctx, span := startSpan(ctx, "OutboxModel.Retry")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3`
+	if _, err := m.DB.ExecContext(ctx, query, next, lastError, id); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// DeletePublished removes messages published before the given time and
+// reports how many were removed.
+func (m *OutboxModel) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "OutboxModel.DeletePublished")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM outbox WHERE published_at < $1`
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return rows, nil
+}
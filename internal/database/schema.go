@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SchemaVersion reports the version and dirty flag recorded by
+// golang-migrate in the schema_migrations table.
+func SchemaVersion(ctx context.Context, db *sql.DB) (uint, bool, error) {
+	ctx, span := startSpan(ctx, "SchemaVersion")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var version uint
+	var dirty bool
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	if err := db.QueryRowContext(ctx, query).Scan(&version, &dirty); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, spanError(span, err)
+	}
+	return version, dirty, nil
+}
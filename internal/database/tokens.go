@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ScopeMagicLink   = "magic-link"
+	ScopeEmailChange = "email-change"
+)
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserId    string    `json:"-"`
+	Scope     string    `json:"-"`
+	Payload   string    `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// TokenInfo describes an issued token without exposing any secret material.
+type TokenInfo struct {
+	Scope     string     `json:"scope"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+// generateToken creates a random token for the user. Only the SHA-256 hash of
+// the plaintext is ever stored in the database.
+func generateToken(userId string, ttl time.Duration, scope, payload string) (*Token, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+		UserId:    userId,
+		Scope:     scope,
+		Payload:   payload,
+		Expiry:    time.Now().Add(ttl),
+	}
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+	return token, nil
+}
+
+func (m *TokenModel) New(ctx context.Context, userId string, ttl time.Duration, scope string) (*Token, error) {
+	return m.NewWithPayload(ctx, userId, ttl, scope, "")
+}
+
+// NewWithPayload creates a token carrying extra data that is handed back when
+// the token is consumed, such as the new address of an email change.
+func (m *TokenModel) NewWithPayload(ctx context.Context, userId string, ttl time.Duration, scope, payload string) (*Token, error) {
+	token, err := generateToken(userId, ttl, scope, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Insert(ctx, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (m *TokenModel) Insert(ctx context.Context, token *Token) error {
+	ctx, span := startSpan(ctx, "TokenModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO tokens (hash, user_id, scope, payload, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserId, token.Scope, token.Payload, token.Expiry); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Consume marks an unused, unexpired token as used and returns it. The update
+// is a single statement so a token can never be redeemed twice. Unknown, used
+// and expired tokens all yield a not-found error.
+func (m *TokenModel) Consume(ctx context.Context, scope, plaintext string) (*Token, error) {
+	ctx, span := startSpan(ctx, "TokenModel.Consume")
+	defer span.End()
+	query := `UPDATE tokens SET used_at = NOW()
+		WHERE hash = $1 AND scope = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, COALESCE(payload, ''), expires_at`
+	return m.consume(ctx, query, scope, plaintext)
+}
+
+// ConsumeForUser is Consume for a token that must belong to userId. The
+// tokens of other users are left unused and yield a not-found error.
+func (m *TokenModel) ConsumeForUser(ctx context.Context, scope, plaintext, userId string) (*Token, error) {
+	ctx, span := startSpan(ctx, "TokenModel.ConsumeForUser")
+	defer span.End()
+	query := `UPDATE tokens SET used_at = NOW()
+		WHERE hash = $1 AND scope = $2 AND user_id = $3 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, COALESCE(payload, ''), expires_at`
+	return m.consume(ctx, query, scope, plaintext, userId)
+}
+
+// consume runs a query redeeming the token with the given hash and scope,
+// followed by args. The caller is expected to have started the span
+// describing the statement.
+func (m *TokenModel) consume(ctx context.Context, query, scope, plaintext string, args ...any) (*Token, error) {
+	span := trace.SpanFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(plaintext))
+	token := &Token{Plaintext: plaintext, Hash: hash[:], Scope: scope}
+	args = append([]any{hash[:], scope}, args...)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&token.UserId, &token.Payload, &token.Expiry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Token not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return token, nil
+}
+
+// DeleteAllForUser removes every token of the given scope belonging to the user.
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, scope, userId string) error {
+	ctx, span := startSpan(ctx, "TokenModel.DeleteAllForUser")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
+	result, err := m.DB.ExecContext(ctx, query, scope, userId)
+	if err != nil {
+		return spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return nil
+}
+
+func (m *TokenModel) GetAllForUser(ctx context.Context, userId string) ([]*TokenInfo, error) {
+	ctx, span := startSpan(ctx, "TokenModel.GetAllForUser")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT scope, created_at, expires_at, used_at FROM tokens WHERE user_id = $1 ORDER BY created_at`
+	rows, err := m.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	tokens := []*TokenInfo{}
+	for rows.Next() {
+		token := &TokenInfo{}
+		if err := rows.Scan(&token.Scope, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt); err != nil {
+			return nil, spanError(span, err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(tokens)))
+	return tokens, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/davidcm146/event-rest-api/internal/database")
+
+// startSpan starts a child span for a model query. Name identifies the
+// statement, e.g. "EventModel.Get".
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+}
+
+// setRows records the number of rows returned or affected by the query.
+func setRows(span trace.Span, rows int64) {
+	span.SetAttributes(attribute.Int64("db.response.returned_rows", rows))
+}
+
+// spanError marks the span as failed and returns err unchanged so it can be
+// used inline in return statements.
+func spanError(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
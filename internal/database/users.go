@@ -5,8 +5,19 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Roles. Admins can read the audit log.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
+// UserModel stores accounts. Every change is recorded in the audit log as
+// part of the same transaction.
 type UserModel struct {
 	DB *sql.DB
 }
@@ -16,56 +27,306 @@ type User struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"-"`
+	Role     string `json:"-"`
 }
 
-func (m *UserModel) Insert(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *UserModel) Insert(ctx context.Context, user *User) error {
+	ctx, span := startSpan(ctx, "UserModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// Check if the user already exists
-	existingUser := &User{}
-	checkQuery := `SELECT id, name, email FROM users WHERE email = $1`
-	err := m.DB.QueryRowContext(ctx, checkQuery, user.Email).Scan(&existingUser.Id, &existingUser.Name, &existingUser.Email)
-
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to check existing user: %w", err)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
 	}
+	defer tx.Rollback()
 
-	if err == nil {
-		// Found an existing user
-		return fmt.Errorf("user with email %s already exists", user.Email)
+	insertQuery := `INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id, role`
+	err = tx.QueryRowContext(ctx, insertQuery, user.Name, user.Email, user.Password).Scan(&user.Id, &user.Role)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to insert user: %w", mapError(err)))
 	}
+	if err := recordAudit(ctx, tx, ActionCreate, EntityUser, user.Id, nil, user); err != nil {
+		return spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
 
-	insertQuery := `INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`
-	err = m.DB.QueryRowContext(ctx, insertQuery, user.Name, user.Email, user.Password).Scan(&user.Id)
+// userSnapshot reads the user inside tx and locks the row for the rest of
+// the transaction. It returns nil if there is no such user.
+func userSnapshot(ctx context.Context, tx *sql.Tx, id string) (*User, error) {
+	query := `SELECT id, name, email, password, role FROM users WHERE id = $1 FOR UPDATE`
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, id).Scan(&user.Id, &user.Name, &user.Email, &user.Password, &user.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to insert user: %w", err)
+		return nil, err
 	}
-	return nil
+	return user, nil
 }
 
-func (m *UserModel) GetUser(query string, args ...interface{}) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// GetUser runs a single-user query. The caller is expected to have started
+// the span describing the statement.
+func (m *UserModel) GetUser(ctx context.Context, query string, args ...interface{}) (*User, error) {
+	span := trace.SpanFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	row := m.DB.QueryRowContext(ctx, query, args...)
 
 	user := &User{}
-	if err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Password); err != nil {
+	if err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Password, &user.Role); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil
+			return nil, errs.NotFound("User not found")
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, spanError(span, fmt.Errorf("failed to get user: %w", err))
 	}
+	setRows(span, 1)
 	return user, nil
 }
 
-func (m *UserModel) GetById(id string) (*User, error) {
-	query := `SELECT id, name, email, password FROM users WHERE id = $1`
-	return m.GetUser(query, id)
+func (m *UserModel) GetById(ctx context.Context, id string) (*User, error) {
+	ctx, span := startSpan(ctx, "UserModel.GetById")
+	defer span.End()
+	query := `SELECT id, name, email, password, role FROM users WHERE id = $1`
+	return m.GetUser(ctx, query, id)
+}
+
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, span := startSpan(ctx, "UserModel.GetByEmail")
+	defer span.End()
+	query := `SELECT id, name, email, password, role FROM users WHERE email = $1`
+	return m.GetUser(ctx, query, email)
+}
+
+// Update saves the user's name and email. It returns a not found error if
+// there is no such user.
+func (m *UserModel) Update(ctx context.Context, user *User) error {
+	ctx, span := startSpan(ctx, "UserModel.Update")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	before, err := userSnapshot(ctx, tx, user.Id)
+	if err != nil {
+		return spanError(span, err)
+	}
+	if before == nil {
+		setRows(span, 0)
+		return errs.NotFound("User not found")
+	}
+	query := `UPDATE users SET name = $1, email = $2 WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, query, user.Name, user.Email, user.Id); err != nil {
+		return spanError(span, fmt.Errorf("failed to update user: %w", mapError(err)))
+	}
+	after := *before
+	after.Name, after.Email = user.Name, user.Email
+	if err := recordAudit(ctx, tx, ActionUpdate, EntityUser, user.Id, before, &after); err != nil {
+		return spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+func (m *UserModel) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	ctx, span := startSpan(ctx, "UserModel.UpdatePassword")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, hashedPassword, id)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to update password: %w", err))
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	if rows == 0 {
+		return nil
+	}
+	// The hash itself is never logged; the entry only records that it changed.
+	if err := recordAudit(ctx, tx, ActionPasswordChange, EntityUser, id, nil, nil); err != nil {
+		return spanError(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
+	}
+	return nil
+}
+
+// accountDeletedReason is the reason recorded for cancelling the events of
+// an owner who deletes their account.
+const accountDeletedReason = "The organizer deleted their account"
+
+// Delete removes the user account. Live events owned by the user are either
+// handed over to transferTo or, when transferTo is empty, cancelled and moved
+// to the trash. Events the user had already trashed are not transferred: like
+// cancelled ones, they stay in the trash without an owner until they are
+// purged. Everything happens in a single transaction.
+func (m *UserModel) Delete(ctx context.Context, id, transferTo string) error {
+	ctx, span := startSpan(ctx, "UserModel.Delete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return spanError(span, err)
+	}
+	defer tx.Rollback()
+
+	before, err := userSnapshot(ctx, tx, id)
+	if err != nil {
+		return spanError(span, err)
+	}
+
+	if transferTo != "" {
+		if err := transferOwnedEvents(ctx, tx, id, transferTo); err != nil {
+			return spanError(span, fmt.Errorf("failed to transfer events: %w", err))
+		}
+	} else if err := cancelOwnedEvents(ctx, tx, id); err != nil {
+		return spanError(span, fmt.Errorf("failed to cancel events: %w", err))
+	}
+
+	query := `DELETE FROM users WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return spanError(span, fmt.Errorf("failed to delete user: %w", err))
+	}
+	if before != nil {
+		if err := recordAudit(ctx, tx, ActionDelete, EntityUser, id, before, nil); err != nil {
+			return spanError(span, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return spanError(span, err)
+	}
+	return nil
+}
+
+// transferOwnedEvents hands the live events of ownerId over to newOwnerId as
+// part of tx, announcing each change in the outbox.
+func transferOwnedEvents(ctx context.Context, tx *sql.Tx, ownerId, newOwnerId string) error {
+	query := `SELECT id FROM events WHERE owner_id = $1 AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+	eventIds, err := returnedIds(ctx, tx, query, ownerId)
+	if err != nil {
+		return err
+	}
+	for _, eventId := range eventIds {
+		before, err := eventSnapshot(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+		query := `UPDATE events SET owner_id = $1, version = version + 1 WHERE id = $2`
+		if _, err := tx.ExecContext(ctx, query, newOwnerId, eventId); err != nil {
+			return err
+		}
+		after, err := eventSnapshot(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, ActionUpdate, EntityEvent, eventId, before, after); err != nil {
+			return err
+		}
+		if err := saveRevision(ctx, tx, eventId); err != nil {
+			return err
+		}
+		if err := publishEventChange(ctx, tx, TopicEventUpdated, after, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelOwnedEvents cancels the events of ownerId that are not in the trash
+// and moves them there, as part of tx. Their attendees are told through the
+// outbox. Events that were already cancelled or completed are only moved to
+// the trash.
+func cancelOwnedEvents(ctx context.Context, tx *sql.Tx, ownerId string) error {
+	query := `SELECT id FROM events WHERE owner_id = $1 AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+	eventIds, err := returnedIds(ctx, tx, query, ownerId)
+	if err != nil {
+		return err
+	}
+	for _, eventId := range eventIds {
+		before, err := eventSnapshot(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+		if CanTransition(before.Status, EventCancelled) {
+			if _, _, err := transitionEvent(ctx, tx, before, EventCancelled, accountDeletedReason, ownerId); err != nil {
+				return err
+			}
+			cancelled, err := eventSnapshot(ctx, tx, eventId)
+			if err != nil {
+				return err
+			}
+			if err := recordAudit(ctx, tx, ActionTransition, EntityEvent, eventId, before, cancelled); err != nil {
+				return err
+			}
+			if err := saveRevision(ctx, tx, eventId); err != nil {
+				return err
+			}
+			if err := publishEventChange(ctx, tx, TopicEventCancelled, cancelled, accountDeletedReason); err != nil {
+				return err
+			}
+			before = cancelled
+		}
+
+		query := `UPDATE events SET deleted_at = NOW(), version = version + 1 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, eventId); err != nil {
+			return err
+		}
+		after, err := eventSnapshot(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, ActionDelete, EntityEvent, eventId, before, after); err != nil {
+			return err
+		}
+		if err := saveRevision(ctx, tx, eventId); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (m *UserModel) GetByEmail(email string) (*User, error) {
-	query := `SELECT id, name, email, password FROM users WHERE email = $1`
-	return m.GetUser(query, email)
+// returnedIds runs a statement ending in RETURNING id and collects the ids.
+func returnedIds(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
 }
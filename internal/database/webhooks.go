@@ -0,0 +1,348 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Webhook event types.
+const (
+	WebhookEventCreated    = "event.created"
+	WebhookEventUpdated    = "event.updated"
+	WebhookEventCancelled  = "event.cancelled"
+	WebhookAttendeeAdded   = "attendee.added"
+	WebhookAttendeeRemoved = "attendee.removed"
+)
+
+// Delivery statuses. A delivery stays pending while it is being retried and
+// fails once it runs out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Webhook is a subscription to changes of the events a user owns or, when it
+// belongs to an organization, of the events any of its members owns.
+// Exactly one of UserId and OrganizationId is set.
+type Webhook struct {
+	Id             string   `json:"id"`
+	UserId         string   `json:"userId,omitempty"`
+	OrganizationId string   `json:"organizationId,omitempty"`
+	URL            string   `json:"url"`
+	EventTypes     []string `json:"eventTypes"`
+	// Secret signs the deliveries. It is only shown when the webhook is
+	// created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery is one notification sent, or to be sent, to a webhook.
+type WebhookDelivery struct {
+	Id             string          `json:"id"`
+	WebhookId      string          `json:"webhookId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" enums:"pending,succeeded,failed"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+
+	// URL and Secret are those of the webhook, filled in by
+	// GetPendingDelivery.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+func (m *WebhookModel) Insert(ctx context.Context, webhook *Webhook) error {
+	ctx, span := startSpan(ctx, "WebhookModel.Insert")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhooks (user_id, organization_id, url, secret, event_types)
+		VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, $3, $4, $5) RETURNING id, created_at`
+	err := m.DB.QueryRowContext(ctx, query, webhook.UserId, webhook.OrganizationId, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes)).Scan(&webhook.Id, &webhook.CreatedAt)
+	if err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
+
+// Get returns a webhook without its secret.
+func (m *WebhookModel) Get(ctx context.Context, id string) (*Webhook, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Get")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	webhook := &Webhook{}
+	err := scanWebhook(m.DB.QueryRowContext(ctx, query, id), webhook)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Webhook not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return webhook, nil
+}
+
+const webhookColumns = `id, COALESCE(user_id::text, ''), COALESCE(organization_id::text, ''), url, event_types, created_at`
+
+func scanWebhook(row interface{ Scan(...any) error }, w *Webhook) error {
+	return row.Scan(&w.Id, &w.UserId, &w.OrganizationId, &w.URL, pq.Array(&w.EventTypes), &w.CreatedAt)
+}
+
+// GetByUser returns the user's own webhooks without their secrets.
+func (m *WebhookModel) GetByUser(ctx context.Context, userId string) ([]*Webhook, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.GetByUser")
+	defer span.End()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY created_at`
+	return m.getWebhooks(ctx, query, userId)
+}
+
+// GetByOrganization returns the webhooks of an organization without their
+// secrets.
+func (m *WebhookModel) GetByOrganization(ctx context.Context, organizationId string) ([]*Webhook, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.GetByOrganization")
+	defer span.End()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE organization_id = $1 ORDER BY created_at`
+	return m.getWebhooks(ctx, query, organizationId)
+}
+
+// getWebhooks runs a webhook list query. The caller is expected to have
+// started the span describing the statement.
+func (m *WebhookModel) getWebhooks(ctx context.Context, query string, args ...any) ([]*Webhook, error) {
+	span := trace.SpanFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		webhook := &Webhook{}
+		if err := scanWebhook(rows, webhook); err != nil {
+			return nil, spanError(span, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(webhooks)))
+	return webhooks, nil
+}
+
+// Delete removes a webhook together with its delivery log.
+func (m *WebhookModel) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "WebhookModel.Delete")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM webhooks WHERE id = $1`
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return spanError(span, err)
+	}
+	rows, _ := result.RowsAffected()
+	setRows(span, rows)
+	return nil
+}
+
+// Enqueue creates a pending delivery of payload to every webhook subscribed
+// to eventType that belongs to ownerId, the owner of the changed event, or to
+// an organization ownerId is a member of. It returns the ids of the deliveries queued for
+// messageId, the outbox message being relayed. Webhooks that already have a
+// delivery for the message are not given another, but its id is returned
+// again so that a relay that was interrupted can finish queueing it.
+func (m *WebhookModel) Enqueue(ctx context.Context, messageId, ownerId, eventType string, payload []byte) ([]string, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Enqueue")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `WITH queued AS (
+			INSERT INTO webhook_deliveries (webhook_id, message_id, event_type, payload, next_attempt_at)
+			SELECT id, $1, $3, $4, NOW() FROM webhooks
+			WHERE $3 = ANY(event_types) AND (user_id = $2
+				OR organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $2))
+			ON CONFLICT (webhook_id, message_id) DO NOTHING
+			RETURNING id
+		)
+		SELECT id FROM queued
+		UNION
+		SELECT id FROM webhook_deliveries WHERE message_id = $1 AND status = 'pending'`
+	// lib/pq sends []byte as bytea, which JSONB does not accept.
+	rows, err := m.DB.QueryContext(ctx, query, messageId, ownerId, eventType, string(payload))
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, spanError(span, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(ids)))
+	return ids, nil
+}
+
+// Replay creates a new pending delivery with the event type and payload of
+// an earlier one, due immediately.
+func (m *WebhookModel) Replay(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Replay")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	replay := &WebhookDelivery{
+		WebhookId: delivery.WebhookId,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+		Status:    DeliveryPending,
+	}
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_type, payload, next_attempt_at)
+		VALUES ($1, $2, $3, NOW()) RETURNING id, next_attempt_at, created_at`
+	err := m.DB.QueryRowContext(ctx, query, replay.WebhookId, replay.EventType, string(replay.Payload)).Scan(&replay.Id, &replay.NextAttemptAt, &replay.CreatedAt)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return replay, nil
+}
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+	COALESCE(last_status_code, 0), COALESCE(last_error, ''), created_at, delivered_at`
+
+func scanDelivery(row interface{ Scan(...any) error }, d *WebhookDelivery, extra ...any) error {
+	var payload []byte
+	dest := append([]any{&d.Id, &d.WebhookId, &d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	d.Payload = payload
+	return nil
+}
+
+// GetDeliveries returns the most recent deliveries of a webhook, newest
+// first.
+func (m *WebhookModel) GetDeliveries(ctx context.Context, webhookId string, limit int) ([]*WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.GetDeliveries")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id LIMIT $2`
+	rows, err := m.DB.QueryContext(ctx, query, webhookId, limit)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		if err := scanDelivery(rows, d); err != nil {
+			return nil, spanError(span, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, spanError(span, err)
+	}
+	setRows(span, int64(len(deliveries)))
+	return deliveries, nil
+}
+
+func (m *WebhookModel) GetDelivery(ctx context.Context, webhookId, id string) (*WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.GetDelivery")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 AND id::text = $2`
+	d := &WebhookDelivery{}
+	if err := scanDelivery(m.DB.QueryRowContext(ctx, query, webhookId, id), d); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Delivery not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return d, nil
+}
+
+// GetPendingDelivery returns a delivery that is still to be sent, together
+// with the URL and secret of its webhook. Deliveries that were sent, gave up
+// or whose webhook was deleted are reported as missing.
+func (m *WebhookModel) GetPendingDelivery(ctx context.Context, id string) (*WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookModel.GetPendingDelivery")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+			COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.created_at, d.delivered_at, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id::text = $1 AND d.status = 'pending'`
+	d := &WebhookDelivery{}
+	if err := scanDelivery(m.DB.QueryRowContext(ctx, query, id), d, &d.URL, &d.Secret); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.NotFound("Delivery not found")
+		}
+		return nil, spanError(span, err)
+	}
+	setRows(span, 1)
+	return d, nil
+}
+
+// RecordAttempt stores the outcome of a delivery attempt: its status,
+// attempt count, last response and when to try next.
+func (m *WebhookModel) RecordAttempt(ctx context.Context, d *WebhookDelivery) error {
+	ctx, span := startSpan(ctx, "WebhookModel.RecordAttempt")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3,
+		last_status_code = NULLIF($4, 0), last_error = NULLIF($5, ''), delivered_at = $6
+		WHERE id = $7`
+	_, err := m.DB.ExecContext(ctx, query, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt, d.Id)
+	if err != nil {
+		return spanError(span, err)
+	}
+	setRows(span, 1)
+	return nil
+}
@@ -0,0 +1,116 @@
+// Package errs defines the typed errors shared by the models and the HTTP
+// layer. Each error has a Kind, which decides the response status, and a
+// message that is safe to show to clients. The wrapped cause is only ever
+// logged.
+package errs
+
+import "errors"
+
+type Kind uint8
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindForbidden
+	KindValidation
+	KindUnauthorized
+	KindPrecondition
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindForbidden:
+		return "forbidden"
+	case KindValidation:
+		return "validation"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindPrecondition:
+		return "precondition_failed"
+	default:
+		return "internal"
+	}
+}
+
+// FieldError describes why a single input field was rejected. Field uses the
+// name the client sent, e.g. the JSON key.
+type FieldError struct {
+	Field   string `json:"field" example:"name"`
+	Code    string `json:"code" example:"required"`
+	Message string `json:"message" example:"name is a required field"`
+}
+
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// E builds an error of the given kind. message is shown to clients, err is
+// the optional underlying cause.
+func E(kind Kind, message string, err error) error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// Invalid is a validation error listing the rejected fields.
+func Invalid(message string, fields []FieldError, err error) error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields, Err: err}
+}
+
+func NotFound(message string) error {
+	return E(KindNotFound, message, nil)
+}
+
+func Conflict(message string) error {
+	return E(KindConflict, message, nil)
+}
+
+func Forbidden(message string) error {
+	return E(KindForbidden, message, nil)
+}
+
+func Validation(message string) error {
+	return E(KindValidation, message, nil)
+}
+
+func Unauthorized(message string) error {
+	return E(KindUnauthorized, message, nil)
+}
+
+// PreconditionFailed reports a conditional request whose precondition, such
+// as If-Match, did not hold.
+func PreconditionFailed(message string) error {
+	return E(KindPrecondition, message, nil)
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or
+// KindInternal when there is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// Is reports whether err is an error of the given kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
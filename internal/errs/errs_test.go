@@ -0,0 +1,60 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"plain error", errors.New("boom"), KindInternal},
+		{"typed", NotFound("Event not found"), KindNotFound},
+		{"wrapped", fmt.Errorf("loading event: %w", Conflict("Already exists")), KindConflict},
+		{"outermost wins", E(KindForbidden, "No access", NotFound("Event not found")), KindForbidden},
+		{"nil", nil, KindInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.want {
+				t.Fatalf("KindOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIs(t *testing.T) {
+	if Is(nil, KindInternal) {
+		t.Fatal("Is(nil) = true")
+	}
+	if !Is(errors.New("boom"), KindInternal) {
+		t.Fatal("untyped errors are not internal")
+	}
+	if !Is(Unauthorized("Invalid token"), KindUnauthorized) || Is(Unauthorized("Invalid token"), KindForbidden) {
+		t.Fatal("Is() does not match on the kind")
+	}
+}
+
+func TestErrorKeepsCause(t *testing.T) {
+	err := E(KindInternal, "Could not load the event", sql.ErrConnDone)
+	if !errors.Is(err, sql.ErrConnDone) {
+		t.Fatal("the cause is not unwrapped")
+	}
+	if got, want := err.Error(), "Could not load the event: "+sql.ErrConnDone.Error(); got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+	if got := Validation("Bad input").Error(); got != "Bad input" {
+		t.Fatalf("Error() = %q, want the message alone", got)
+	}
+}
+
+func TestKindString(t *testing.T) {
+	if KindPrecondition.String() != "precondition_failed" || Kind(200).String() != "internal" {
+		t.Fatal("unexpected kind names")
+	}
+}
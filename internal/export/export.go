@@ -0,0 +1,124 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+// Data is everything held about a single user.
+type Data struct {
+	GeneratedAt time.Time             `json:"generatedAt"`
+	Profile     *database.User        `json:"profile"`
+	OwnedEvents []*database.Event     `json:"ownedEvents"`
+	Attendance  []*database.Event     `json:"attendance"`
+	Sessions    []*database.TokenInfo `json:"sessions"`
+	// Activity is the user's own changes as recorded in the audit log.
+	Activity []*database.AuditEntry `json:"activity"`
+}
+
+// Collect gathers everything held about the user.
+func Collect(ctx context.Context, models database.Models, user *database.User) (*Data, error) {
+	ownedEvents, err := models.Events.GetByOwner(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	attendance, err := models.Attendees.GetAttendance(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := models.Tokens.GetAllForUser(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := models.Audit.Query(ctx, database.AuditFilter{ActorId: user.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Data{
+		GeneratedAt: time.Now().UTC(),
+		Profile:     user,
+		OwnedEvents: ownedEvents,
+		Attendance:  attendance,
+		Sessions:    sessions,
+		Activity:    activity,
+	}, nil
+}
+
+// WriteZip writes the export as a zip archive containing a single JSON file
+// with everything plus one CSV file per record type.
+func WriteZip(w io.Writer, data *Data) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("export.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+
+	profile := [][]string{
+		{"id", "name", "email"},
+		{data.Profile.Id, data.Profile.Name, data.Profile.Email},
+	}
+	if err := writeCSV(zw, "profile.csv", profile); err != nil {
+		return err
+	}
+	if err := writeCSV(zw, "owned_events.csv", eventRecords(data.OwnedEvents)); err != nil {
+		return err
+	}
+	if err := writeCSV(zw, "attendance.csv", eventRecords(data.Attendance)); err != nil {
+		return err
+	}
+
+	sessions := [][]string{{"scope", "created_at", "expires_at", "used_at"}}
+	for _, s := range data.Sessions {
+		usedAt := ""
+		if s.UsedAt != nil {
+			usedAt = s.UsedAt.Format(time.RFC3339)
+		}
+		sessions = append(sessions, []string{s.Scope, s.CreatedAt.Format(time.RFC3339), s.ExpiresAt.Format(time.RFC3339), usedAt})
+	}
+	if err := writeCSV(zw, "sessions.csv", sessions); err != nil {
+		return err
+	}
+
+	activity := [][]string{{"created_at", "action", "entity_type", "entity_id", "request_id", "ip"}}
+	for _, a := range data.Activity {
+		activity = append(activity, []string{a.CreatedAt.Format(time.RFC3339), a.Action, a.EntityType, a.EntityId, a.RequestId, a.IP})
+	}
+	if err := writeCSV(zw, "activity.csv", activity); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func eventRecords(events []*database.Event) [][]string {
+	records := [][]string{{"id", "name", "owner_id", "description", "date", "location"}}
+	for _, e := range events {
+		records = append(records, []string{e.Id, e.Name, e.OwnerId, e.Description, e.Date, e.Location})
+	}
+	return records
+}
+
+func writeCSV(zw *zip.Writer, name string, records [][]string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+func TestWriteZip(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := &Data{
+		GeneratedAt: now,
+		Profile:     &database.User{Id: "user-1", Name: "Jane", Email: "jane@example.com", Password: "hash"},
+		OwnedEvents: []*database.Event{{Id: "event-1", Name: "Go meetup", OwnerId: "user-1", Description: "Talks, pizza", Date: "2026-02-01", Location: "Hanoi"}},
+		Sessions:    []*database.TokenInfo{{Scope: database.ScopeMagicLink, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		Activity:    []*database.AuditEntry{{CreatedAt: now, Action: database.ActionCreate, EntityType: database.EntityEvent, EntityId: "event-1"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"export.json", "profile.csv", "owned_events.csv", "attendance.csv", "sessions.csv", "activity.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("archive is missing %s", name)
+		}
+	}
+
+	var decoded Data
+	if err := json.Unmarshal(files["export.json"], &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Profile.Email != "jane@example.com" || len(decoded.OwnedEvents) != 1 || !decoded.GeneratedAt.Equal(now) {
+		t.Fatalf("export.json = %+v", decoded)
+	}
+	if bytes.Contains(files["export.json"], []byte("hash")) {
+		t.Fatal("export.json contains the password hash")
+	}
+
+	events := readCSV(t, files["owned_events.csv"])
+	if len(events) != 2 || events[1][0] != "event-1" || events[1][3] != "Talks, pizza" {
+		t.Fatalf("owned_events.csv = %v", events)
+	}
+	if attendance := readCSV(t, files["attendance.csv"]); len(attendance) != 1 {
+		t.Fatalf("attendance.csv = %v, want only the header", attendance)
+	}
+	sessions := readCSV(t, files["sessions.csv"])
+	if len(sessions) != 2 || sessions[1][0] != database.ScopeMagicLink || sessions[1][3] != "" {
+		t.Fatalf("sessions.csv = %v", sessions)
+	}
+}
+
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
@@ -0,0 +1,78 @@
+// Package jobs runs background work from the Postgres job queue. Jobs are
+// enqueued with typed arguments, claimed with FOR UPDATE SKIP LOCKED so that
+// any number of workers can share the queue, retried with backoff when they
+// fail and moved to the dead letters once they run out of attempts.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+// DefaultMaxAttempts is how many times a job runs before it is given up,
+// unless it is enqueued with MaxAttempts.
+const DefaultMaxAttempts = 5
+
+// Args are the arguments of a job. They are stored as JSON, and Kind names
+// the handler that runs them.
+type Args interface {
+	Kind() string
+}
+
+// Job is a claimed job with its decoded arguments.
+type Job[T Args] struct {
+	Id   string
+	Args T
+	// Attempt counts from 1.
+	Attempt     int
+	MaxAttempts int
+	// RetryAt is when the job runs again if this attempt fails. It is zero
+	// on the last attempt.
+	RetryAt time.Time
+}
+
+// Option changes how a job is enqueued.
+type Option func(*database.Job)
+
+// RunAt schedules the job for t instead of right away.
+func RunAt(t time.Time) Option {
+	return func(job *database.Job) { job.RunAt = t }
+}
+
+// MaxAttempts sets how many times the job runs before it is given up.
+func MaxAttempts(n int) Option {
+	return func(job *database.Job) { job.MaxAttempts = n }
+}
+
+// UniqueKey keeps a job from being enqueued again while one with the same
+// key exists, whatever its status.
+func UniqueKey(key string) Option {
+	return func(job *database.Job) { job.UniqueKey = key }
+}
+
+// Enqueue adds a job with the given arguments to the queue. It reports false
+// when a job with the same unique key already exists.
+func Enqueue(ctx context.Context, store database.JobStore, args Args, opts ...Option) (bool, error) {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return false, err
+	}
+	job := &database.Job{Kind: args.Kind(), Args: encoded, MaxAttempts: DefaultMaxAttempts}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return store.Enqueue(ctx, job)
+}
+
+// DefaultBackoff is how long to wait before running a job again after
+// attempts failures: ten seconds, doubling every time, capped at an hour.
+func DefaultBackoff(attempts int) time.Duration {
+	const limit = time.Hour
+	if attempts > 10 {
+		return limit
+	}
+	return min(10*time.Second<<max(attempts-1, 0), limit)
+}
@@ -0,0 +1,341 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+// memStore is an in-memory job queue with the semantics of JobModel.
+type memStore struct {
+	mu   sync.Mutex
+	seq  int
+	jobs []*database.Job
+	// lockedUntil holds the claim of running jobs.
+	lockedUntil map[string]time.Time
+}
+
+func newMemStore() *memStore {
+	return &memStore{lockedUntil: make(map[string]time.Time)}
+}
+
+func (s *memStore) Enqueue(ctx context.Context, job *database.Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if job.UniqueKey != "" && j.UniqueKey == job.UniqueKey {
+			return false, nil
+		}
+	}
+	s.seq++
+	job.Id = fmt.Sprintf("job-%d", s.seq)
+	job.Status = database.JobPending
+	job.CreatedAt = time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = job.CreatedAt
+	}
+	stored := *job
+	s.jobs = append(s.jobs, &stored)
+	return true, nil
+}
+
+func (s *memStore) Claim(ctx context.Context, kind string, now time.Time, lease time.Duration, limit int) ([]*database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := []*database.Job{}
+	for _, j := range s.jobs {
+		due := j.Status == database.JobPending && !j.RunAt.After(now) ||
+			j.Status == database.JobRunning && !s.lockedUntil[j.Id].After(now)
+		if j.Kind != kind || !due || len(claimed) == limit {
+			continue
+		}
+		j.Status = database.JobRunning
+		j.Attempts++
+		s.lockedUntil[j.Id] = now.Add(lease)
+		job := *j
+		claimed = append(claimed, &job)
+	}
+	return claimed, nil
+}
+
+func (s *memStore) finish(id, status, lastError string, runAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Id == id {
+			j.Status = status
+			j.LastError = lastError
+			if !runAt.IsZero() {
+				j.RunAt = runAt
+			}
+		}
+	}
+}
+
+func (s *memStore) Complete(ctx context.Context, id string) error {
+	s.finish(id, database.JobSucceeded, "", time.Time{})
+	return nil
+}
+
+func (s *memStore) Retry(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	s.finish(id, database.JobPending, lastError, runAt)
+	return nil
+}
+
+func (s *memStore) Bury(ctx context.Context, id, lastError string) error {
+	s.finish(id, database.JobDead, lastError, time.Time{})
+	return nil
+}
+
+func (s *memStore) DeleteSucceeded(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *memStore) get(id string) database.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Id == id {
+			return *j
+		}
+	}
+	return database.Job{}
+}
+
+type greet struct {
+	Name string `json:"name"`
+}
+
+func (greet) Kind() string { return "greet" }
+
+type flaky struct {
+	Failures int `json:"failures"`
+}
+
+func (flaky) Kind() string { return "flaky" }
+
+func newWorker(store *memStore) *Worker {
+	return NewWorker(store, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Hour, 4)
+}
+
+func enqueue(t *testing.T, store *memStore, args Args, opts ...Option) string {
+	t.Helper()
+	ok, err := Enqueue(context.Background(), store, args, opts...)
+	if err != nil || !ok {
+		t.Fatalf("Enqueue() = %v, %v", ok, err)
+	}
+	return store.jobs[len(store.jobs)-1].Id
+}
+
+func TestRunOnce(t *testing.T) {
+	store := newMemStore()
+	w := newWorker(store)
+	var mu sync.Mutex
+	var greeted []string
+	Register(w, func(ctx context.Context, job *Job[greet]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		greeted = append(greeted, job.Args.Name)
+		return nil
+	})
+
+	id := enqueue(t, store, greet{Name: "Jane"})
+	now := time.Now()
+	later := enqueue(t, store, greet{Name: "Joe"}, RunAt(now.Add(time.Hour)))
+
+	if n := w.RunOnce(context.Background(), now); n != 1 {
+		t.Fatalf("ran %d jobs, want 1", n)
+	}
+	if !slices.Equal(greeted, []string{"Jane"}) {
+		t.Fatalf("greeted %v, want Jane", greeted)
+	}
+	if job := store.get(id); job.Status != database.JobSucceeded || job.Attempts != 1 {
+		t.Fatalf("job = %+v, want succeeded on the first attempt", job)
+	}
+	if job := store.get(later); job.Status != database.JobPending {
+		t.Fatalf("scheduled job = %+v, want it left pending", job)
+	}
+
+	w.RunOnce(context.Background(), now.Add(time.Hour))
+	if !slices.Equal(greeted, []string{"Jane", "Joe"}) {
+		t.Fatalf("greeted %v, want Jane and Joe", greeted)
+	}
+}
+
+func TestEnqueueUniqueKey(t *testing.T) {
+	store := newMemStore()
+	enqueue(t, store, greet{Name: "Jane"}, UniqueKey("greet:jane"))
+	ok, err := Enqueue(context.Background(), store, greet{Name: "Jane"}, UniqueKey("greet:jane"))
+	if err != nil || ok {
+		t.Fatalf("Enqueue() of a duplicate = %v, %v, want false", ok, err)
+	}
+	if len(store.jobs) != 1 {
+		t.Fatalf("queued %d jobs, want 1", len(store.jobs))
+	}
+}
+
+func TestRetriesThenBuries(t *testing.T) {
+	store := newMemStore()
+	w := newWorker(store)
+	var retries []time.Time
+	Register(w, func(ctx context.Context, job *Job[flaky]) error {
+		retries = append(retries, job.RetryAt)
+		if job.Attempt <= job.Args.Failures {
+			return errors.New("temporarily unavailable")
+		}
+		return nil
+	}, Backoff(func(attempts int) time.Duration { return time.Duration(attempts) * time.Minute }))
+
+	recovers := enqueue(t, store, flaky{Failures: 1}, MaxAttempts(3))
+	now := time.Now()
+	w.RunOnce(context.Background(), now)
+	job := store.get(recovers)
+	if job.Status != database.JobPending || job.LastError == "" || !job.RunAt.Equal(now.Add(time.Minute)) || !retries[0].Equal(job.RunAt) {
+		t.Fatalf("job = %+v, retry at %v, want a retry in a minute", job, retries[0])
+	}
+
+	// Not due yet.
+	if n := w.RunOnce(context.Background(), now.Add(time.Second)); n != 0 {
+		t.Fatalf("ran %d jobs before the backoff elapsed", n)
+	}
+	w.RunOnce(context.Background(), now.Add(time.Minute))
+	if job := store.get(recovers); job.Status != database.JobSucceeded || job.Attempts != 2 {
+		t.Fatalf("job = %+v, want success on the second attempt", job)
+	}
+
+	dies := enqueue(t, store, flaky{Failures: 10}, MaxAttempts(2))
+	retries = nil
+	now = time.Now()
+	w.RunOnce(context.Background(), now)
+	w.RunOnce(context.Background(), now.Add(time.Hour))
+	if job := store.get(dies); job.Status != database.JobDead || job.Attempts != 2 || job.LastError == "" {
+		t.Fatalf("job = %+v, want dead after 2 attempts", job)
+	}
+	if len(retries) != 2 || retries[0].IsZero() || !retries[1].IsZero() {
+		t.Fatalf("retry times = %v, want none on the last attempt", retries)
+	}
+	if n := w.RunOnce(context.Background(), now.Add(24*time.Hour)); n != 0 {
+		t.Fatalf("ran %d dead jobs", n)
+	}
+}
+
+func TestPanicsAndBadArgumentsFail(t *testing.T) {
+	store := newMemStore()
+	w := newWorker(store)
+	Register(w, func(ctx context.Context, job *Job[greet]) error {
+		panic("boom")
+	})
+
+	panics := enqueue(t, store, greet{Name: "Jane"})
+	store.jobs = append(store.jobs, &database.Job{Id: "bad", Kind: "greet", Args: []byte(`{"name":1}`), Status: database.JobPending, MaxAttempts: 1})
+	w.RunOnce(context.Background(), time.Now())
+
+	if job := store.get(panics); job.Status != database.JobPending || job.LastError != "panic: boom" {
+		t.Fatalf("job = %+v, want a retry after the panic", job)
+	}
+	if job := store.get("bad"); job.Status != database.JobDead {
+		t.Fatalf("job = %+v, want dead", job)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	store := newMemStore()
+	w := newWorker(store)
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	Register(w, func(ctx context.Context, job *Job[greet]) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, Concurrency(2))
+	for i := range 3 {
+		enqueue(t, store, greet{Name: fmt.Sprint(i)})
+	}
+
+	now := time.Now()
+	if n := w.dispatch(context.Background(), now); n != 2 {
+		t.Fatalf("started %d jobs, want 2", n)
+	}
+	<-started
+	<-started
+	if n := w.dispatch(context.Background(), now); n != 0 {
+		t.Fatalf("started %d jobs while both slots were busy", n)
+	}
+	close(release)
+	w.wg.Wait()
+	if n := w.RunOnce(context.Background(), now); n != 1 {
+		t.Fatalf("ran %d jobs after the slots freed up, want 1", n)
+	}
+}
+
+func TestExpiredClaimsAreRetaken(t *testing.T) {
+	store := newMemStore()
+	w := newWorker(store)
+	Register(w, func(ctx context.Context, job *Job[greet]) error { return nil }, Timeout(time.Second))
+	id := enqueue(t, store, greet{Name: "Jane"})
+
+	// A worker claims the job and dies.
+	now := time.Now()
+	if _, err := store.Claim(context.Background(), "greet", now, time.Minute, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.RunOnce(context.Background(), now.Add(30*time.Second)); n != 0 {
+		t.Fatalf("ran %d jobs while the claim held", n)
+	}
+	w.RunOnce(context.Background(), now.Add(time.Minute))
+	if job := store.get(id); job.Status != database.JobSucceeded || job.Attempts != 2 {
+		t.Fatalf("job = %+v, want it finished by the second worker", job)
+	}
+}
+
+func TestRunWaitsForRunningJobs(t *testing.T) {
+	store := newMemStore()
+	w := NewWorker(store, slog.New(slog.NewTextHandler(io.Discard, nil)), 10*time.Millisecond, 1)
+	started := make(chan struct{})
+	Register(w, func(ctx context.Context, job *Job[greet]) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Err()
+	})
+	id := enqueue(t, store, greet{Name: "Jane"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	<-started
+	cancel()
+	<-done
+	if job := store.get(id); job.Status != database.JobSucceeded {
+		t.Fatalf("job = %+v, want it allowed to finish on shutdown", job)
+	}
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{5, 160 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := DefaultBackoff(tt.attempts); got != tt.want {
+			t.Errorf("DefaultBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+)
+
+// DefaultTimeout is how long a job may run, unless its kind is registered
+// with Timeout.
+const DefaultTimeout = time.Minute
+
+// Worker claims due jobs of the registered kinds and runs them.
+type Worker struct {
+	store        database.JobStore
+	logger       *slog.Logger
+	pollInterval time.Duration
+	concurrency  int
+	kinds        []*kind
+	wg           sync.WaitGroup
+}
+
+type kind struct {
+	name        string
+	run         func(ctx context.Context, job *database.Job, retryAt time.Time) error
+	concurrency int
+	timeout     time.Duration
+	backoff     func(attempts int) time.Duration
+	running     atomic.Int64
+}
+
+// KindOption changes how the jobs of a kind are run.
+type KindOption func(*kind)
+
+// Concurrency sets how many jobs of the kind run at once in this worker.
+func Concurrency(n int) KindOption {
+	return func(k *kind) { k.concurrency = n }
+}
+
+// Timeout sets how long a job of the kind may run. The job's claim lasts a
+// minute longer, so that no other worker picks it up in the meantime.
+func Timeout(d time.Duration) KindOption {
+	return func(k *kind) { k.timeout = d }
+}
+
+// Backoff sets how long to wait before running a job of the kind again after
+// attempts failures.
+func Backoff(backoff func(attempts int) time.Duration) KindOption {
+	return func(k *kind) { k.backoff = backoff }
+}
+
+// NewWorker returns a worker that looks for due jobs every pollInterval and
+// runs up to concurrency jobs of each kind at once.
+func NewWorker(store database.JobStore, logger *slog.Logger, pollInterval time.Duration, concurrency int) *Worker {
+	return &Worker{
+		store:        store,
+		logger:       logger,
+		pollInterval: pollInterval,
+		concurrency:  concurrency,
+	}
+}
+
+// Register makes w run the jobs whose arguments are of type T with handle.
+// A job whose handler returns an error or panics is retried until it runs
+// out of attempts.
+func Register[T Args](w *Worker, handle func(ctx context.Context, job *Job[T]) error, opts ...KindOption) {
+	var zero T
+	k := &kind{
+		name:        zero.Kind(),
+		concurrency: w.concurrency,
+		timeout:     DefaultTimeout,
+		backoff:     DefaultBackoff,
+	}
+	for _, existing := range w.kinds {
+		if existing.name == k.name {
+			panic(fmt.Sprintf("jobs: kind %q registered twice", k.name))
+		}
+	}
+	k.run = func(ctx context.Context, job *database.Job, retryAt time.Time) error {
+		typed := &Job[T]{
+			Id:          job.Id,
+			Attempt:     job.Attempts,
+			MaxAttempts: job.MaxAttempts,
+			RetryAt:     retryAt,
+		}
+		if err := json.Unmarshal(job.Args, &typed.Args); err != nil {
+			return fmt.Errorf("invalid arguments: %w", err)
+		}
+		return handle(ctx, typed)
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	w.kinds = append(w.kinds, k)
+}
+
+// Run claims and runs due jobs every poll interval until ctx is cancelled,
+// then waits for the jobs already running to finish.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for w.dispatch(ctx, time.Now()) > 0 && ctx.Err() == nil {
+		}
+		select {
+		case <-ctx.Done():
+			w.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce runs the jobs that are due at now, as many of each kind as the
+// concurrency allows, waits for them to finish and reports how many ran.
+func (w *Worker) RunOnce(ctx context.Context, now time.Time) int {
+	n := w.dispatch(ctx, now)
+	w.wg.Wait()
+	return n
+}
+
+// dispatch claims due jobs for the free slots of every kind and starts them.
+// Running jobs are not cancelled with ctx; they are given their timeout to
+// finish.
+func (w *Worker) dispatch(ctx context.Context, now time.Time) int {
+	started := 0
+	for _, k := range w.kinds {
+		free := k.concurrency - int(k.running.Load())
+		if free <= 0 {
+			continue
+		}
+		jobs, err := w.store.Claim(ctx, k.name, now, k.timeout+time.Minute, free)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.logger.ErrorContext(ctx, "failed to claim jobs", "kind", k.name, "error", err)
+			}
+			continue
+		}
+		for _, job := range jobs {
+			k.running.Add(1)
+			w.wg.Add(1)
+			go func() {
+				defer w.wg.Done()
+				defer k.running.Add(-1)
+				w.execute(context.WithoutCancel(ctx), k, job, now)
+			}()
+		}
+		started += len(jobs)
+	}
+	return started
+}
+
+// execute runs a claimed job and records the outcome: success, a retry with
+// backoff, or burial once the job has run out of attempts.
+func (w *Worker) execute(ctx context.Context, k *kind, job *database.Job, now time.Time) {
+	var retryAt time.Time
+	if job.Attempts < job.MaxAttempts {
+		retryAt = now.Add(k.backoff(job.Attempts))
+	}
+
+	err := w.run(ctx, k, job, retryAt)
+	logger := w.logger.With("job_id", job.Id, "kind", job.Kind, "attempt", job.Attempts)
+	switch {
+	case err == nil:
+		if err := w.store.Complete(ctx, job.Id); err != nil {
+			logger.ErrorContext(ctx, "failed to record job success", "error", err)
+		}
+	case retryAt.IsZero():
+		logger.ErrorContext(ctx, "job failed for the last time", "error", err)
+		if err := w.store.Bury(ctx, job.Id, err.Error()); err != nil {
+			logger.ErrorContext(ctx, "failed to record job failure", "error", err)
+		}
+	default:
+		logger.WarnContext(ctx, "job failed", "retry_at", retryAt, "error", err)
+		if err := w.store.Retry(ctx, job.Id, retryAt, err.Error()); err != nil {
+			logger.ErrorContext(ctx, "failed to reschedule job", "error", err)
+		}
+	}
+}
+
+// run calls the handler of the job's kind within its timeout, turning a
+// panic into an error.
+func (w *Worker) run(ctx context.Context, k *kind, job *database.Job, retryAt time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return k.run(ctx, job, retryAt)
+}
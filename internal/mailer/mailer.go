@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends plain-text emails.
+type Mailer interface {
+	Send(recipient, subject, body string) error
+}
+
+// New returns an SMTP backed mailer, or a mailer that only logs messages when
+// no SMTP host is configured (handy for local development).
+func New(host string, port int, username, password, sender string) Mailer {
+	if host == "" {
+		return &logMailer{sender: sender}
+	}
+	return &smtpMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		sender:   sender,
+	}
+}
+
+type smtpMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	sender   string
+}
+
+func (m *smtpMailer) Send(recipient, subject, body string) error {
+	from, err := mail.ParseAddress(m.sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", m.sender, err)
+	}
+	msg, err := message(from, recipient, subject, body)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	addr := fmt.Sprintf("%s:%d", m.host, m.port)
+	return smtp.SendMail(addr, auth, from.Address, []string{recipient}, msg)
+}
+
+// message formats an email. The subject is encoded as an RFC 2047 word when
+// it is not plain ASCII, so a line break in it cannot start a new header.
+func message(from *mail.Address, recipient, subject, body string) ([]byte, error) {
+	if strings.ContainsAny(recipient, "\r\n") {
+		return nil, errors.New("invalid recipient: contains a line break")
+	}
+	msg := strings.Join([]string{
+		"From: " + from.String(),
+		"To: " + recipient,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+	return []byte(msg), nil
+}
+
+type logMailer struct {
+	sender string
+}
+
+// Send logs the message without its body, which may hold login links and
+// other secrets.
+func (m *logMailer) Send(recipient, subject, body string) error {
+	slog.Debug("email not sent, no SMTP host configured",
+		"from", m.sender, "to", recipient, "subject", subject, "body_bytes", len(body))
+	return nil
+}
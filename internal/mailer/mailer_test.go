@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"log/slog"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestMessageEncodesSubject(t *testing.T) {
+	from := &mail.Address{Name: "Event API", Address: "no-reply@example.com"}
+	msg, err := message(from, "jane@example.com", "Reminder: Go\r\nBcc: eve@example.com", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(string(msg), "\r\n\r\n")
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("subject injected a header:\n%s", header)
+		}
+	}
+	if !strings.Contains(header, "From: \"Event API\" <no-reply@example.com>") {
+		t.Fatalf("header = %q, want the sender's address", header)
+	}
+}
+
+func TestMessageRejectsLineBreakInRecipient(t *testing.T) {
+	from := &mail.Address{Address: "no-reply@example.com"}
+	if _, err := message(from, "jane@example.com\r\nBcc: eve@example.com", "Hi", "Hello"); err == nil {
+		t.Fatal("message() accepted a recipient with a line break")
+	}
+}
+
+func TestLogMailerOmitsBody(t *testing.T) {
+	var buf strings.Builder
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	m := New("", 0, "", "", "no-reply@example.com")
+	if err := m.Send("jane@example.com", "Your login link", "https://example.com/magic-link?token=SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "SECRET") {
+		t.Fatalf("log contains the body: %s", buf.String())
+	}
+}
@@ -0,0 +1,78 @@
+// Package mergepatch implements JSON Merge Patch (RFC 7396).
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ContentType is the media type of a merge patch document.
+const ContentType = "application/merge-patch+json"
+
+// ErrNotObject is returned when the patch is not a JSON object. A non-object
+// patch would replace the whole document, which no endpoint allows.
+var ErrNotObject = errors.New("merge patch must be a JSON object")
+
+// Apply applies patch to doc and returns the patched document. Members set
+// to null in the patch are removed; objects are merged recursively and any
+// other value replaces the original.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var patchValue any
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, err
+	}
+	patchObject, ok := patchValue.(map[string]any)
+	if !ok {
+		return nil, ErrNotObject
+	}
+
+	var target any
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(merge(target, patchObject))
+}
+
+// Keys returns the top-level member names of a patch object, i.e. the fields
+// the client asked to change.
+func Keys(patch []byte) ([]string, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, ErrNotObject
+		}
+		return nil, err
+	}
+	if members == nil {
+		return nil, ErrNotObject
+	}
+
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func merge(target any, patch map[string]any) any {
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok {
+			targetObject[key] = merge(targetObject[key], nested)
+			continue
+		}
+		targetObject[key] = value
+	}
+	return targetObject
+}
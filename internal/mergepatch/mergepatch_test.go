@@ -0,0 +1,74 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// The cases are the examples from RFC 7396, appendix A, restricted to object
+// patches.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Fatalf("Apply(%s, %s): %v", tt.doc, tt.patch, err)
+		}
+		assertJSONEqual(t, got, tt.want)
+	}
+}
+
+func TestApplyRejectsNonObjectPatch(t *testing.T) {
+	for _, patch := range []string{`["c"]`, `"c"`, `null`, `42`} {
+		if _, err := Apply([]byte(`{"a":"b"}`), []byte(patch)); !errors.Is(err, ErrNotObject) {
+			t.Errorf("Apply with patch %s: got %v, want ErrNotObject", patch, err)
+		}
+	}
+	if _, err := Apply([]byte(`{}`), []byte(`{`)); err == nil {
+		t.Error("Apply accepted malformed JSON")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keys, err := Keys([]byte(`{"name":"x","location":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got keys %v, want name and location", keys)
+	}
+	if _, err := Keys([]byte(`[]`)); !errors.Is(err, ErrNotObject) {
+		t.Fatalf("got %v, want ErrNotObject", err)
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "events_api"
+
+// Metrics holds the application's Prometheus collectors on a dedicated
+// registry so nothing registered globally by dependencies leaks out.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	EventsCreated prometheus.Counter
+	RSVPs         prometheus.Counter
+	Logins        *prometheus.CounterVec
+}
+
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route and status class.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		EventsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_created_total",
+			Help:      "Number of events created.",
+		}),
+		RSVPs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rsvps_total",
+			Help:      "Number of attendees added to events.",
+		}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Number of login attempts by method and result.",
+		}, []string{"method", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "events"),
+		m.requests,
+		m.duration,
+		m.EventsCreated,
+		m.RSVPs,
+		m.Logins,
+	)
+	return m
+}
+
+// ObserveRequest records a finished HTTP request. Route must be the route
+// template (e.g. /api/v1/events/:id), never the raw path, to keep label
+// cardinality bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requests.WithLabelValues(method, route, fmt.Sprintf("%dxx", status/100)).Inc()
+	m.duration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// LoginSucceeded and LoginFailed count login attempts for the given method
+// (password or magic-link).
+func (m *Metrics) LoginSucceeded(method string) {
+	m.Logins.WithLabelValues(method, "success").Inc()
+}
+
+func (m *Metrics) LoginFailed(method string) {
+	m.Logins.WithLabelValues(method, "failure").Inc()
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,96 @@
+package tasks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/export"
+	"github.com/davidcm146/event-rest-api/internal/jobs"
+)
+
+// GenerateExport writes the archive of a pending personal data export.
+type GenerateExport struct {
+	ExportId string `json:"exportId"`
+}
+
+func (GenerateExport) Kind() string { return "export.generate" }
+
+// QueueExport queues the generation of a pending export.
+func (t *Tasks) QueueExport(ctx context.Context, exportId string) error {
+	args := GenerateExport{ExportId: exportId}
+	_, err := jobs.Enqueue(ctx, t.models.Jobs, args, jobs.MaxAttempts(3), jobs.UniqueKey(args.Kind()+":"+exportId))
+	return err
+}
+
+// generateExport writes the archive of a pending export to the export
+// directory and records the outcome. The export is marked as failed once
+// the job runs out of attempts.
+func (t *Tasks) generateExport(ctx context.Context, job *jobs.Job[GenerateExport]) error {
+	exp, err := t.models.Exports.Get(ctx, job.Args.ExportId)
+	if err != nil {
+		if errs.Is(err, errs.KindNotFound) {
+			return nil
+		}
+		return err
+	}
+	if exp.Status != database.ExportPending {
+		return nil
+	}
+
+	if err := t.writeExport(ctx, exp); err != nil {
+		if job.RetryAt.IsZero() {
+			if err := t.models.Exports.MarkFailed(ctx, exp.Id, "Export could not be generated"); err != nil {
+				t.logger.ErrorContext(ctx, "failed to record export failure", "export_id", exp.Id, "error", err)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *Tasks) writeExport(ctx context.Context, exp *database.Export) error {
+	user, err := t.models.Users.GetById(ctx, exp.UserId)
+	if err != nil {
+		return err
+	}
+	data, err := export.Collect(ctx, t.models, user)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(t.config.Export.Dir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(t.config.Export.Dir, exp.Id+".zip")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := export.WriteZip(f, data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return t.models.Exports.MarkCompleted(ctx, exp.Id, path)
+}
+
+// deleteExpiredExports removes expired exports and their archives.
+func (t *Tasks) deleteExpiredExports(ctx context.Context) error {
+	paths, err := t.models.Exports.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			t.logger.ErrorContext(ctx, "failed to remove export file", "path", path, "error", err)
+		}
+	}
+	return nil
+}
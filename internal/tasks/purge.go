@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/jobs"
+)
+
+// Purge deletes what has outlived its retention period: events in the trash,
+// expired exports and succeeded jobs. It runs every EVENT_PURGE_INTERVAL.
+type Purge struct{}
+
+func (Purge) Kind() string { return "purge" }
+
+// schedulePurge queues the purge due at at. The unique key is derived from
+// at, so processes that start at the same time queue a single purge.
+func (t *Tasks) schedulePurge(ctx context.Context, at time.Time) error {
+	args := Purge{}
+	_, err := jobs.Enqueue(ctx, t.models.Jobs, args,
+		jobs.RunAt(at),
+		jobs.UniqueKey(fmt.Sprintf("%s:%d", args.Kind(), at.Unix())),
+	)
+	return err
+}
+
+// purge queues the next purge first, so that a failing purge does not stop
+// the ones after it, then deletes everything that has expired.
+func (t *Tasks) purge(ctx context.Context, job *jobs.Job[Purge]) error {
+	now := time.Now()
+	interval := t.config.Events.PurgeInterval
+	if err := t.schedulePurge(ctx, now.Truncate(interval).Add(interval)); err != nil {
+		return err
+	}
+
+	var failures []error
+	purged, err := t.models.Events.PurgeDeleted(ctx, now.AddDate(0, 0, -t.config.Events.RetentionDays))
+	if err != nil {
+		failures = append(failures, fmt.Errorf("events: %w", err))
+	} else if purged > 0 {
+		t.logger.InfoContext(ctx, "purged deleted events", "count", purged)
+	}
+
+	if err := t.deleteExpiredExports(ctx); err != nil {
+		failures = append(failures, fmt.Errorf("exports: %w", err))
+	}
+
+	deleted, err := t.models.Jobs.DeleteSucceeded(ctx, now.Add(-t.config.Jobs.Retention))
+	if err != nil {
+		failures = append(failures, fmt.Errorf("jobs: %w", err))
+	} else if deleted > 0 {
+		t.logger.InfoContext(ctx, "deleted finished jobs", "count", deleted)
+	}
+	return errors.Join(failures...)
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/jobs"
+	"github.com/davidcm146/event-rest-api/internal/utils"
+)
+
+// SendReminder emails the attendees of an event that it is coming up. Date
+// is the event's date when the reminder was scheduled, as 2006-01-02.
+type SendReminder struct {
+	EventId string `json:"eventId"`
+	Date    string `json:"date"`
+}
+
+func (SendReminder) Kind() string { return "event.reminder" }
+
+// ScheduleReminder queues the reminder of a published event, due
+// EVENT_REMINDER_LEAD before the day it takes place. Each date of an event is
+// reminded of once, however often the event is saved; a reminder whose
+// event has moved to another date is dropped when it runs.
+func (t *Tasks) ScheduleReminder(ctx context.Context, event *database.Event) error {
+	if event.Status != database.EventPublished {
+		return nil
+	}
+	date, err := utils.ParseDate(event.Date)
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(date) {
+		return nil
+	}
+	args := SendReminder{EventId: event.Id, Date: date.Format("2006-01-02")}
+	_, err = jobs.Enqueue(ctx, t.models.Jobs, args,
+		jobs.RunAt(date.Add(-t.config.Events.ReminderLead)),
+		jobs.UniqueKey(fmt.Sprintf("%s:%s:%s", args.Kind(), args.EventId, args.Date)),
+	)
+	return err
+}
+
+// sendReminder emails every attendee of the event, unless it was deleted,
+// is no longer published, moved to another date or has already started.
+// Failed emails are logged rather than retried, so that the other attendees
+// are not emailed twice.
+func (t *Tasks) sendReminder(ctx context.Context, job *jobs.Job[SendReminder]) error {
+	event, err := t.models.Events.Get(ctx, job.Args.EventId)
+	if err != nil {
+		if errs.Is(err, errs.KindNotFound) {
+			return nil
+		}
+		return err
+	}
+	date, err := utils.ParseDate(event.Date)
+	if err != nil {
+		return err
+	}
+	if event.Status != database.EventPublished || date.Format("2006-01-02") != job.Args.Date || !time.Now().Before(date) {
+		return nil
+	}
+
+	attendees, err := t.models.Attendees.GetAttendeesByEventId(ctx, event.Id)
+	if err != nil {
+		return err
+	}
+	for _, attendee := range attendees {
+		body := fmt.Sprintf("Hi %s,\n\nThis is a reminder that \"%s\" takes place on %s at %s.\n\nSee you there!\n",
+			attendee.Name, event.Name, date.Format("Monday, 2 January 2006"), event.Location)
+		if err := t.mailer.Send(attendee.Email, "Reminder: "+event.Name, body); err != nil {
+			t.logger.ErrorContext(ctx, "event reminder: failed to send email", "event_id", event.Id, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Package tasks holds the background jobs of the API: event reminders,
+// personal data exports, purges and webhook deliveries. Both cmd/api and
+// cmd/worker run them, so they only depend on the models and the outside
+// services they talk to.
+package tasks
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/config"
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/jobs"
+	"github.com/davidcm146/event-rest-api/internal/mailer"
+	"github.com/davidcm146/event-rest-api/internal/webhook"
+)
+
+// Config is the part of the configuration the tasks depend on.
+type Config struct {
+	Export   config.Export
+	Events   config.Events
+	Webhooks config.Webhooks
+	Jobs     config.Jobs
+}
+
+type Tasks struct {
+	config  Config
+	models  database.Models
+	mailer  mailer.Mailer
+	webhook *webhook.Client
+	logger  *slog.Logger
+}
+
+func New(cfg Config, models database.Models, mailer mailer.Mailer, webhook *webhook.Client, logger *slog.Logger) *Tasks {
+	return &Tasks{
+		config:  cfg,
+		models:  models,
+		mailer:  mailer,
+		webhook: webhook,
+		logger:  logger,
+	}
+}
+
+// Worker returns a job worker that runs every kind of task.
+func (t *Tasks) Worker() *jobs.Worker {
+	w := jobs.NewWorker(t.models.Jobs, t.logger, t.config.Jobs.PollInterval, t.config.Jobs.Concurrency)
+	jobs.Register(w, t.sendReminder)
+	jobs.Register(w, t.generateExport, jobs.Timeout(10*time.Minute))
+	jobs.Register(w, t.purge, jobs.Concurrency(1), jobs.Timeout(10*time.Minute))
+	// The request times out on its own; the rest covers recording the
+	// outcome.
+	jobs.Register(w, t.deliverWebhook, jobs.Timeout(t.config.Webhooks.Timeout+30*time.Second), jobs.Backoff(webhook.Backoff))
+	return w
+}
+
+// Start schedules the recurring tasks. It is safe to call from every
+// process that runs a worker.
+func (t *Tasks) Start(ctx context.Context) error {
+	return t.schedulePurge(ctx, time.Now().Truncate(t.config.Events.PurgeInterval))
+}
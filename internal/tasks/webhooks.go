@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/database"
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/jobs"
+	"github.com/davidcm146/event-rest-api/internal/webhook"
+)
+
+// DeliverWebhook sends a queued webhook delivery.
+type DeliverWebhook struct {
+	DeliveryId string `json:"deliveryId"`
+}
+
+func (DeliverWebhook) Kind() string { return "webhook.deliver" }
+
+// QueueWebhookDeliveries queues the sending of pending deliveries. A
+// delivery that is already queued is not queued again.
+func (t *Tasks) QueueWebhookDeliveries(ctx context.Context, deliveryIds ...string) error {
+	for _, id := range deliveryIds {
+		args := DeliverWebhook{DeliveryId: id}
+		_, err := jobs.Enqueue(ctx, t.models.Jobs, args,
+			jobs.MaxAttempts(t.config.Webhooks.MaxAttempts),
+			jobs.UniqueKey(args.Kind()+":"+id),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverWebhook sends a delivery once and records the outcome in the
+// delivery log. Failed deliveries are retried with exponential backoff until
+// WEBHOOK_MAX_ATTEMPTS is reached. Deliveries whose webhook was deleted in
+// the meantime are dropped.
+func (t *Tasks) deliverWebhook(ctx context.Context, job *jobs.Job[DeliverWebhook]) error {
+	d, err := t.models.Webhooks.GetPendingDelivery(ctx, job.Args.DeliveryId)
+	if err != nil {
+		if errs.Is(err, errs.KindNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	code, sendErr := t.webhook.Send(ctx, webhook.Request{
+		Id:        d.Id,
+		EventType: d.EventType,
+		URL:       d.URL,
+		Secret:    d.Secret,
+		Body:      d.Payload,
+	})
+
+	d.Attempts++
+	d.LastStatusCode = code
+	d.LastError = ""
+	d.NextAttemptAt = nil
+	switch {
+	case sendErr == nil:
+		d.Status = database.DeliverySucceeded
+		delivered := time.Now()
+		d.DeliveredAt = &delivered
+	case job.RetryAt.IsZero():
+		d.Status = database.DeliveryFailed
+		d.LastError = sendErr.Error()
+	default:
+		d.Status = database.DeliveryPending
+		d.LastError = sendErr.Error()
+		d.NextAttemptAt = &job.RetryAt
+	}
+
+	if err := t.models.Webhooks.RecordAttempt(ctx, d); err != nil {
+		t.logger.ErrorContext(ctx, "failed to record webhook delivery", "delivery_id", d.Id, "error", err)
+	}
+	return sendErr
+}
@@ -5,11 +5,29 @@ import (
 	"time"
 )
 
-// ParseAndFormatDate parses a date string like "02/01/2006" and returns it in "2006-01-02" format
+// DateLayout is the format event dates are accepted in.
+const DateLayout = "02/01/2006"
+
+// ParseDate parses a date in DateLayout. Dates read back from the database
+// (RFC 3339) are accepted as well.
+func ParseDate(input string) (time.Time, error) {
+	parsedDate, err := time.Parse(DateLayout, input)
+	if err != nil {
+		var rfcErr error
+		if parsedDate, rfcErr = time.Parse(time.RFC3339, input); rfcErr != nil {
+			return time.Time{}, fmt.Errorf("invalid date format: %w", err)
+		}
+	}
+	return parsedDate, nil
+}
+
+// ParseAndFormatDate parses a date string like "02/01/2006" and returns it in "2006-01-02" format.
+// Dates read back from the database (RFC 3339) are accepted as well, so a stored event can be
+// written again unchanged.
 func ParseAndFormatDate(input string) (string, error) {
-	parsedDate, err := time.Parse("02/01/2006", input)
+	parsedDate, err := ParseDate(input)
 	if err != nil {
-		return "", fmt.Errorf("invalid date format: %w", err)
+		return "", err
 	}
 	return parsedDate.Format("2006-01-02"), nil
 }
@@ -0,0 +1,225 @@
+// Package validation configures request validation: custom rules, JSON field
+// names and translated messages. Failures are turned into errs.FieldError
+// lists so clients can point at the offending field.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/utils"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/vi"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	vitranslations "github.com/go-playground/validator/v10/translations/vi"
+)
+
+// Translators holds the message catalogues for the supported languages.
+// English is the fallback.
+type Translators struct {
+	uni *ut.UniversalTranslator
+}
+
+// messages are the translations of the custom rules and of decoding
+// failures, keyed by locale and then by tag.
+var messages = map[string]map[string]string{
+	"en": {
+		"notblank": "{0} must not be blank",
+		"future":   "{0} must not be in the past",
+		"type":     "{0} must be of type {1}",
+	},
+	"vi": {
+		"notblank": "{0} không được để trống",
+		"future":   "{0} không được là ngày trong quá khứ",
+		"type":     "{0} phải có kiểu {1}",
+	},
+}
+
+// Setup registers the custom rules and translations on gin's validator. It
+// must run before the first request is bound.
+func Setup() (*Translators, error) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil, errors.New("validation: unexpected validator engine")
+	}
+
+	v.RegisterTagNameFunc(jsonName)
+	if err := v.RegisterValidation("notblank", notBlank); err != nil {
+		return nil, err
+	}
+	if err := v.RegisterValidation("future", future); err != nil {
+		return nil, err
+	}
+
+	english := en.New()
+	uni := ut.New(english, english, vi.New())
+
+	register := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": entranslations.RegisterDefaultTranslations,
+		"vi": vitranslations.RegisterDefaultTranslations,
+	}
+	for locale, registerDefaults := range register {
+		trans, _ := uni.GetTranslator(locale)
+		if err := registerDefaults(v, trans); err != nil {
+			return nil, fmt.Errorf("validation: register %s translations: %w", locale, err)
+		}
+		for tag, text := range messages[locale] {
+			if err := trans.Add(tag, text, true); err != nil {
+				return nil, fmt.Errorf("validation: register %s message for %s: %w", locale, tag, err)
+			}
+			if tag == "type" {
+				continue
+			}
+			err := v.RegisterTranslation(tag, trans,
+				func(ut.Translator) error { return nil },
+				func(t ut.Translator, fe validator.FieldError) string {
+					msg, _ := t.T(fe.Tag(), fe.Field())
+					return msg
+				})
+			if err != nil {
+				return nil, fmt.Errorf("validation: register %s message for %s: %w", locale, tag, err)
+			}
+		}
+	}
+
+	return &Translators{uni: uni}, nil
+}
+
+// For picks the translator matching an Accept-Language header.
+func (t *Translators) For(acceptLanguage string) ut.Translator {
+	var locales []string
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		// Only the primary language matters: "vi-VN" uses "vi".
+		primary, _, _ := strings.Cut(tag, "-")
+		locales = append(locales, strings.ToLower(primary))
+	}
+	trans, _ := t.uni.FindTranslator(locales...)
+	return trans
+}
+
+// Partial validates only the fields of s whose JSON names are listed. It is
+// used for partial updates, where untouched fields keep their stored values
+// and should not be re-validated.
+func Partial(s any, jsonNames ...string) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("validation: unexpected validator engine")
+	}
+
+	t := reflect.TypeOf(s)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var fields []string
+	for _, name := range jsonNames {
+		for i := 0; i < t.NumField(); i++ {
+			if jsonName(t.Field(i)) == name {
+				fields = append(fields, t.Field(i).Name)
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return v.StructPartial(s, fields...)
+}
+
+// Error converts a binding failure into a validation error carrying one
+// entry per rejected field.
+func Error(err error, trans ut.Translator) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]errs.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, errs.FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Code:    fe.Tag(),
+				Message: fe.Translate(trans),
+			})
+		}
+		return errs.Invalid("The request body failed validation", fields, err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		msg, _ := trans.T("type", typeErr.Field, typeErr.Type.String())
+		return errs.Invalid("The request body failed validation", []errs.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: msg,
+		}}, err)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return errs.E(errs.KindValidation, "The request body must not be empty", err)
+	}
+	return errs.E(errs.KindValidation, "The request body must be valid JSON", err)
+}
+
+// jsonName reports fields by their JSON key so errors match what the client
+// sent.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// fieldPath drops the top-level struct name from a namespace such as
+// "Event.name".
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// notBlank rejects strings that are empty once surrounding whitespace is
+// trimmed.
+func notBlank(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	if field.Kind() != reflect.String {
+		return false
+	}
+	return strings.TrimSpace(field.String()) != ""
+}
+
+// future accepts dates from today onwards. Strings are parsed with the event
+// date layout; comparisons are by day, so an event later today is valid.
+func future(fl validator.FieldLevel) bool {
+	var date time.Time
+	switch value := fl.Field().Interface().(type) {
+	case time.Time:
+		date = value
+	case string:
+		parsed, err := time.Parse(utils.DateLayout, value)
+		if err != nil {
+			// Malformed dates are reported by the datetime rule.
+			return true
+		}
+		date = parsed
+	default:
+		return false
+	}
+
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	return !date.Before(today)
+}
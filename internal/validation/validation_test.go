@@ -0,0 +1,132 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidcm146/event-rest-api/internal/errs"
+	"github.com/davidcm146/event-rest-api/internal/utils"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// setup registers the rules on gin's validator once for the whole package.
+var setup = sync.OnceValues(Setup)
+
+func translators(t *testing.T) *Translators {
+	t.Helper()
+	trans, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trans
+}
+
+type eventRequest struct {
+	Name string `json:"name" binding:"required,notblank,max=10"`
+	Date string `json:"date" binding:"required,future"`
+	Tags []tag  `json:"tags" binding:"dive"`
+}
+
+type tag struct {
+	Label string `json:"label" binding:"notblank"`
+}
+
+func validate(t *testing.T, request eventRequest) []errs.FieldError {
+	t.Helper()
+	trans := translators(t)
+	err := binding.Validator.ValidateStruct(&request)
+	if err == nil {
+		return nil
+	}
+	var e *errs.Error
+	if !errors.As(Error(err, trans.For("en")), &e) {
+		t.Fatalf("Error() did not return an *errs.Error: %v", err)
+	}
+	return e.Fields
+}
+
+func TestRules(t *testing.T) {
+	today := time.Now().Format(utils.DateLayout)
+	yesterday := time.Now().AddDate(0, 0, -1).Format(utils.DateLayout)
+
+	tests := []struct {
+		name    string
+		request eventRequest
+		want    []string
+	}{
+		{"valid", eventRequest{Name: "Go meetup", Date: today}, nil},
+		{"blank name", eventRequest{Name: "   ", Date: today}, []string{"name:notblank"}},
+		{"past date", eventRequest{Name: "Go meetup", Date: yesterday}, []string{"date:future"}},
+		{"nested field", eventRequest{Name: "Go meetup", Date: today, Tags: []tag{{Label: ""}}}, []string{"tags[0].label:notblank"}},
+		{"several fields", eventRequest{Name: "A much too long name"}, []string{"name:max", "date:required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range validate(t, tt.request) {
+				got = append(got, f.Field+":"+f.Code)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslatedMessages(t *testing.T) {
+	trans := translators(t)
+	err := binding.Validator.ValidateStruct(&eventRequest{Name: " ", Date: time.Now().Format(utils.DateLayout)})
+
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "name must not be blank"},
+		{"vi-VN,vi;q=0.9,en;q=0.8", "name không được để trống"},
+		{"fr", "name must not be blank"},
+	}
+	for _, tt := range tests {
+		var e *errs.Error
+		errors.As(Error(err, trans.For(tt.acceptLanguage)), &e)
+		if len(e.Fields) != 1 || e.Fields[0].Message != tt.want {
+			t.Errorf("For(%q): fields = %+v, want %q", tt.acceptLanguage, e.Fields, tt.want)
+		}
+	}
+}
+
+func TestPartial(t *testing.T) {
+	translators(t)
+	// Only the listed fields are validated.
+	if err := Partial(&eventRequest{Name: "Go meetup"}, "name"); err != nil {
+		t.Fatalf("Partial() = %v, want the missing date ignored", err)
+	}
+	if err := Partial(&eventRequest{Name: " "}, "name"); err == nil {
+		t.Fatal("Partial() accepted a blank name")
+	}
+	if err := Partial(&eventRequest{}); err != nil {
+		t.Fatalf("Partial() without fields = %v", err)
+	}
+}
+
+func TestDecodingErrors(t *testing.T) {
+	trans := translators(t).For("en")
+
+	var request eventRequest
+	typeErr := json.Unmarshal([]byte(`{"name": 1}`), &request)
+	var e *errs.Error
+	if !errors.As(Error(typeErr, trans), &e) || len(e.Fields) != 1 || e.Fields[0].Field != "name" || e.Fields[0].Code != "type" {
+		t.Fatalf("type error = %+v, want it reported on name", e)
+	}
+
+	if err := Error(io.EOF, trans); !errs.Is(err, errs.KindValidation) || !strings.Contains(err.Error(), "must not be empty") {
+		t.Fatalf("Error(io.EOF) = %v", err)
+	}
+	if err := Error(errors.New("invalid character"), trans); !errs.Is(err, errs.KindValidation) {
+		t.Fatalf("Error() of malformed JSON = %v", err)
+	}
+}
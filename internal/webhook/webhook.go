@@ -0,0 +1,173 @@
+// Package webhook signs and sends outgoing webhook requests.
+//
+// Every request carries an X-Webhook-Signature header of the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256>", where the HMAC is computed with
+// the subscription secret over "<t>.<body>". Receivers should recompute it,
+// compare in constant time and reject stale timestamps to stop replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	IdHeader        = "X-Webhook-Id"
+	EventHeader     = "X-Webhook-Event"
+)
+
+// ErrInvalidSignature is returned by Verify when a signature header is
+// malformed, does not match the body or is too old.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+// Verify checks a signature header against body. Signatures made more than
+// tolerance before now are rejected.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+	seconds, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signature, err := hex.DecodeString(v1)
+	if err != nil || !hmac.Equal(signature, mac(secret, t, body)) {
+		return ErrInvalidSignature
+	}
+	if now.Sub(time.Unix(seconds, 0)) > tolerance {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Backoff is how long to wait before retrying a delivery that has failed
+// attempts times: 30 seconds, doubling with every attempt, capped at 6 hours.
+func Backoff(attempts int) time.Duration {
+	const base, limit = 30 * time.Second, 6 * time.Hour
+	if attempts < 1 {
+		return base
+	}
+	if attempts > 20 {
+		return limit
+	}
+	return min(base<<(attempts-1), limit)
+}
+
+// Request is one delivery attempt.
+type Request struct {
+	Id        string
+	EventType string
+	URL       string
+	Secret    string
+	Body      []byte
+}
+
+// Client sends webhook requests.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client whose requests time out after timeout. Unless
+// allowPrivate is set, it refuses to connect to loopback, private and
+// link-local addresses so that subscriptions cannot be used to probe the
+// internal network.
+func NewClient(timeout time.Duration, allowPrivate bool) *Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &Client{http: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// Redirects would bypass the subscription's URL, so they count as
+		// failures.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send posts the request and returns the response status code. Any status
+// outside 2xx is reported as an error along with the code.
+func (c *Client) Send(ctx context.Context, r Request) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "event-rest-api-webhooks/1")
+	req.Header.Set(IdHeader, r.Id)
+	req.Header.Set(EventHeader, r.EventType)
+	req.Header.Set(SignatureHeader, Sign(r.Secret, time.Now(), r.Body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
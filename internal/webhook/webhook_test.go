@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"type":"event.created"}`)
+	header := Sign("secret", now, body)
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Fatalf("header = %q", header)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", "secret", header, body, now, false},
+		{"within tolerance", "secret", header, body, now.Add(5 * time.Minute), false},
+		{"stale", "secret", header, body, now.Add(6 * time.Minute), true},
+		{"wrong secret", "other", header, body, now, true},
+		{"tampered body", "secret", header, []byte(`{"type":"event.cancelled"}`), now, true},
+		{"tampered timestamp", "secret", strings.Replace(header, "t=1700000000", "t=1700000001", 1), body, now, true},
+		{"missing signature", "secret", "t=1700000000", body, now, true},
+		{"malformed", "secret", "garbage", body, now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Verify() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Verify() = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	r := Request{Id: "1", EventType: "event.created", URL: server.URL, Secret: "secret", Body: []byte("{}")}
+	if _, err := NewClient(time.Second, false).Send(context.Background(), r); err == nil {
+		t.Fatal("Send() to a loopback address succeeded")
+	}
+	if code, err := NewClient(time.Second, true).Send(context.Background(), r); err != nil || code != http.StatusOK {
+		t.Fatalf("Send() = %d, %v, want 200", code, err)
+	}
+}